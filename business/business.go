@@ -0,0 +1,241 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package business
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+// Operator combining the states of the sub rules
+type Operator string
+
+const (
+	// OperatorAnd results in the worst state
+	OperatorAnd Operator = "and"
+	// OperatorOr results in the best state
+	OperatorOr Operator = "or"
+	// OperatorMin is OK if at least Min sub rules are OK, otherwise the Min-th best state
+	OperatorMin Operator = "min"
+	// OperatorPercent is OK if at least Percent of the sub rules are OK, otherwise like OperatorMin
+	OperatorPercent Operator = "percent"
+)
+
+// Rule of a business process, either a leaf naming a child state or an operator over sub rules,
+// e.g. a web cluster which is OK if at least 2 of 3 nodes are OK and the load balancer is not CRITICAL:
+//
+//	{"name": "web cluster", "operator": "and", "rules": [
+//		{"name": "nodes", "operator": "min", "min": 2, "rules": [{"child": "web1"}, {"child": "web2"}, {"child": "web3"}]},
+//		{"child": "lb", "accept": [1]}]}
+type Rule struct {
+	Name     string   `json:"name,omitempty"`
+	Operator Operator `json:"operator,omitempty"`
+	Rules    []Rule   `json:"rules,omitempty"`
+	// Child is the name of the child state of a leaf
+	Child   string  `json:"child,omitempty"`
+	Min     int     `json:"min,omitempty"`
+	Percent float64 `json:"percent,omitempty"`
+	// Accept lists states treated as OK, e.g. WARNING for "not CRITICAL"
+	Accept []icinga.ExitCode `json:"accept,omitempty"`
+}
+
+// ParseRule parses and validates a rule in JSON
+func ParseRule(data []byte) (*Rule, error) {
+	r := new(Rule)
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, err
+	}
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate checks the rule and all sub rules
+func (r *Rule) Validate() error {
+	for _, code := range r.Accept {
+		if code < icinga.ExitOk || code > icinga.ExitUnknown {
+			return fmt.Errorf("rule %s accepts the invalid state %d", r.label(), code)
+		}
+	}
+
+	if r.Operator == "" {
+		if r.Child == "" || len(r.Rules) > 0 {
+			return fmt.Errorf("rule %s needs either a child or an operator with rules", r.label())
+		}
+		return nil
+	}
+
+	if r.Child != "" {
+		return fmt.Errorf("rule %s has a child and an operator", r.label())
+	}
+	if len(r.Rules) == 0 {
+		return fmt.Errorf("rule %s has no rules", r.label())
+	}
+	switch r.Operator {
+	case OperatorAnd, OperatorOr:
+	case OperatorMin:
+		if r.Min < 1 || r.Min > len(r.Rules) {
+			return fmt.Errorf("rule %s needs a minimum between 1 and %d", r.label(), len(r.Rules))
+		}
+	case OperatorPercent:
+		if r.Percent <= 0 || r.Percent > 100 {
+			return fmt.Errorf("rule %s needs a percentage between 0 and 100", r.label())
+		}
+	default:
+		return fmt.Errorf("rule %s has the unknown operator %s", r.label(), r.Operator)
+	}
+
+	for i := range r.Rules {
+		if err := r.Rules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Rule) label() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	if r.Child != "" {
+		return r.Child
+	}
+	return string(r.Operator)
+}
+
+// evaluation of a rule
+type evaluation struct {
+	state       icinga.ExitCode
+	label       string
+	explanation string
+	children    []evaluation
+}
+
+// severity orders exit codes as icinga.ExitCode.GetResultCode: OK, UNKNOWN, WARNING, CRITICAL
+func severity(code icinga.ExitCode) int {
+	switch code {
+	case icinga.ExitOk:
+		return 0
+	case icinga.ExitWarning:
+		return 2
+	case icinga.ExitCritical:
+		return 3
+	default:
+		return 1
+	}
+}
+
+// Evaluate evaluates the rule with the child states, missing children are UNKNOWN. The
+// message explains the state of the rule, the long output the states of all sub rules.
+func (r *Rule) Evaluate(states map[string]icinga.Result) icinga.Result {
+	if err := r.Validate(); err != nil {
+		return icinga.Result{Code: icinga.ExitUnknown, Message: err.Error()}
+	}
+
+	e := r.evaluate(states)
+	var lines []string
+	for _, c := range e.children {
+		lines = append(lines, c.lines(0)...)
+	}
+	return icinga.Result{
+		Code:       e.state,
+		Message:    e.String(),
+		LongOutput: strings.Join(lines, "\n"),
+	}
+}
+
+func (e evaluation) String() string {
+	if e.explanation == "" {
+		return e.label
+	}
+	return e.label + ": " + e.explanation
+}
+
+func (e evaluation) lines(depth int) []string {
+	lines := []string{fmt.Sprintf("%s[%s] %s", strings.Repeat("  ", depth), e.state.String(), e.String())}
+	for _, c := range e.children {
+		lines = append(lines, c.lines(depth+1)...)
+	}
+	return lines
+}
+
+func (r *Rule) evaluate(states map[string]icinga.Result) evaluation {
+	var e evaluation
+	if r.Operator == "" {
+		result, ok := states[r.Child]
+		if !ok {
+			result = icinga.Result{Code: icinga.ExitUnknown, Message: "no state"}
+		}
+		e.state = icinga.ExitCodeOf(int(result.Code))
+		e.explanation = result.Message
+	} else {
+		e = r.combine(states)
+	}
+	e.label = r.label()
+
+	for _, code := range r.Accept {
+		if e.state == code && code != icinga.ExitOk {
+			e.explanation = strings.TrimSpace(fmt.Sprintf("%s (%s accepted)", e.explanation, code.String()))
+			e.state = icinga.ExitOk
+		}
+	}
+	return e
+}
+
+func (r *Rule) combine(states map[string]icinga.Result) evaluation {
+	var e evaluation
+	var sorted []icinga.ExitCode
+	ok := 0
+	for i := range r.Rules {
+		c := r.Rules[i].evaluate(states)
+		e.children = append(e.children, c)
+		sorted = append(sorted, c.state)
+		if c.state == icinga.ExitOk {
+			ok++
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return severity(sorted[i]) < severity(sorted[j]) })
+
+	n := len(r.Rules)
+	switch r.Operator {
+	case OperatorAnd:
+		e.state = sorted[n-1]
+		e.explanation = fmt.Sprintf("all of %d", n)
+	case OperatorOr:
+		e.state = sorted[0]
+		e.explanation = fmt.Sprintf("any of %d", n)
+	case OperatorMin:
+		e.state = sorted[r.Min-1]
+		e.explanation = fmt.Sprintf("at least %d of %d", r.Min, n)
+	case OperatorPercent:
+		min := int(math.Ceil(r.Percent * float64(n) / 100))
+		e.state = sorted[min-1]
+		e.explanation = fmt.Sprintf("at least %s%% of %d", formatPercent(r.Percent), n)
+	}
+	e.explanation = fmt.Sprintf("%s OK (%d OK)", e.explanation, ok)
+	return e
+}
+
+func formatPercent(p float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", p), "0"), ".")
+}
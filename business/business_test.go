@@ -0,0 +1,124 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package business
+
+import (
+	"strings"
+	"testing"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+const webCluster = `{"name": "web cluster", "operator": "and", "rules": [
+	{"name": "nodes", "operator": "min", "min": 2, "rules": [{"child": "web1"}, {"child": "web2"}, {"child": "web3"}]},
+	{"child": "lb", "accept": [1]}]}`
+
+func states(web1, web2, web3, lb icinga.ExitCode) map[string]icinga.Result {
+	return map[string]icinga.Result{
+		"web1": {Code: web1, Message: "HTTP " + web1.String()},
+		"web2": {Code: web2},
+		"web3": {Code: web3},
+		"lb":   {Code: lb, Message: "backends"},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	r, err := ParseRule([]byte(webCluster))
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	result := r.Evaluate(states(icinga.ExitOk, icinga.ExitCritical, icinga.ExitOk, icinga.ExitWarning))
+	if result.Code != icinga.ExitOk || result.Message != "web cluster: all of 2 OK (2 OK)" {
+		t.Errorf("Unexpected result %q", result)
+	}
+	want := "[OK] nodes: at least 2 of 3 OK (2 OK)\n" +
+		"  [OK] web1: HTTP OK\n" +
+		"  [CRITICAL] web2\n" +
+		"  [OK] web3\n" +
+		"[OK] lb: backends (WARNING accepted)"
+	if result.LongOutput != want {
+		t.Errorf("Unexpected long output:\n%s", result.LongOutput)
+	}
+
+	expectCode(t, r, states(icinga.ExitOk, icinga.ExitWarning, icinga.ExitCritical, icinga.ExitOk), icinga.ExitWarning)
+	expectCode(t, r, states(icinga.ExitCritical, icinga.ExitCritical, icinga.ExitOk, icinga.ExitOk), icinga.ExitCritical)
+	expectCode(t, r, states(icinga.ExitOk, icinga.ExitOk, icinga.ExitOk, icinga.ExitCritical), icinga.ExitCritical)
+	expectCode(t, r, states(icinga.ExitOk, icinga.ExitUnknown, icinga.ExitUnknown, icinga.ExitOk), icinga.ExitUnknown)
+
+	result = r.Evaluate(map[string]icinga.Result{"web1": {}, "web2": {}, "web3": {}})
+	if result.Code != icinga.ExitUnknown || !strings.HasSuffix(result.LongOutput, "\n[UNKNOWN] lb: no state") {
+		t.Errorf("Expecting missing child to be UNKNOWN, got %q", result)
+	}
+}
+
+func TestOperators(t *testing.T) {
+	children := []Rule{{Child: "a"}, {Child: "b"}, {Child: "c"}, {Child: "d"}}
+	s := map[string]icinga.Result{
+		"a": {Code: icinga.ExitOk},
+		"b": {Code: icinga.ExitWarning},
+		"c": {Code: icinga.ExitUnknown},
+		"d": {Code: icinga.ExitCritical},
+	}
+
+	expectCode(t, &Rule{Operator: OperatorAnd, Rules: children}, s, icinga.ExitCritical)
+	expectCode(t, &Rule{Operator: OperatorAnd, Rules: children[:3]}, s, icinga.ExitWarning)
+	expectCode(t, &Rule{Operator: OperatorOr, Rules: children}, s, icinga.ExitOk)
+	expectCode(t, &Rule{Operator: OperatorOr, Rules: children[1:]}, s, icinga.ExitUnknown)
+	expectCode(t, &Rule{Operator: OperatorMin, Min: 1, Rules: children}, s, icinga.ExitOk)
+	expectCode(t, &Rule{Operator: OperatorMin, Min: 3, Rules: children}, s, icinga.ExitWarning)
+	expectCode(t, &Rule{Operator: OperatorPercent, Percent: 25, Rules: children}, s, icinga.ExitOk)
+	expectCode(t, &Rule{Operator: OperatorPercent, Percent: 50, Rules: children}, s, icinga.ExitUnknown)
+	expectCode(t, &Rule{Operator: OperatorPercent, Percent: 100, Rules: children}, s, icinga.ExitCritical)
+	expectCode(t, &Rule{Operator: OperatorAnd, Rules: children, Accept: []icinga.ExitCode{icinga.ExitCritical}}, s, icinga.ExitOk)
+
+	result := (&Rule{Name: "half", Operator: OperatorPercent, Percent: 50, Rules: children}).Evaluate(s)
+	if result.Message != "half: at least 50% of 4 OK (1 OK)" {
+		t.Errorf("Unexpected message %q", result.Message)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for definition, message := range map[string]string{
+		`{"name": "empty"}`:                                                  "rule empty needs either a child or an operator with rules",
+		`{"child": "a", "rules": [{"child": "b"}]}`:                          "rule a needs either a child or an operator with rules",
+		`{"child": "a", "operator": "and", "rules": [{"child": "b"}]}`:       "rule a has a child and an operator",
+		`{"operator": "or"}`:                                                 "rule or has no rules",
+		`{"operator": "min", "min": 3, "rules": [{"child": "a"}]}`:           "rule min needs a minimum between 1 and 1",
+		`{"operator": "percent", "rules": [{"child": "a"}]}`:                 "rule percent needs a percentage between 0 and 100",
+		`{"operator": "xor", "rules": [{"child": "a"}]}`:                     "rule xor has the unknown operator xor",
+		`{"operator": "and", "rules": [{"child": "a", "accept": [4]}]}`:      "rule a accepts the invalid state 4",
+		`{"operator": "and", "rules": [{"operator": "and", "name": "sub"}]}`: "rule sub has no rules",
+	} {
+		_, err := ParseRule([]byte(definition))
+		if err == nil || err.Error() != message {
+			t.Errorf("Expecting error %q for %s, got %v", message, definition, err)
+		}
+	}
+
+	result := (&Rule{Operator: OperatorAnd}).Evaluate(nil)
+	if result.Code != icinga.ExitUnknown || result.Message != "rule and has no rules" {
+		t.Errorf("Expecting UNKNOWN for an invalid rule, got %q", result)
+	}
+}
+
+func expectCode(t *testing.T, r *Rule, s map[string]icinga.Result, code icinga.ExitCode) {
+	t.Helper()
+	if result := r.Evaluate(s); result.Code != code {
+		t.Errorf("Expecting %s, got %q", code, result)
+	}
+}
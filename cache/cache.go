@@ -0,0 +1,181 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// Cache stores check results on disk, one file per key
+type Cache struct {
+	Dir string
+	// MaxAge of a fresh result
+	MaxAge time.Duration
+	// MaxStale is the maximum age of a stale result returned while another process
+	// refreshes it, older results are not returned
+	MaxStale time.Duration
+}
+
+// entry is the stored result
+type entry struct {
+	Time       time.Time       `json:"time"`
+	Code       icinga.ExitCode `json:"code"`
+	Message    string          `json:"message"`
+	LongOutput string          `json:"long_output,omitempty"`
+	PerfData   []string        `json:"perfdata,omitempty"`
+}
+
+// CreateCache creates and returns a new Cache storing results in the directory which are
+// fresh for maxAge and returned as stale for up to 10 times maxAge
+func CreateCache(dir string, maxAge time.Duration) *Cache {
+	c := new(Cache)
+	c.Dir = dir
+	c.MaxAge = maxAge
+	c.MaxStale = 10 * maxAge
+
+	return c
+}
+
+// Key returns the key of a check with the arguments, e.g. os.Args
+func Key(args ...string) string {
+	h := sha256.New()
+	for _, a := range args {
+		h.Write([]byte(a))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Wrap returns a check function returning the cached result of the check
+func (c *Cache) Wrap(key string, check icinga.CheckFunc) icinga.CheckFunc {
+	return func(ctx context.Context) icinga.Result {
+		return c.Run(ctx, key, check)
+	}
+}
+
+// Run returns the cached result of the key while it is fresh, otherwise it runs the check and
+// stores its result. Only one process refreshes a result at a time, the others return the
+// stale result marked in the message or wait for the refreshed result.
+func (c *Cache) Run(ctx context.Context, key string, check icinga.CheckFunc) icinga.Result {
+	path := filepath.Join(c.Dir, key+".json")
+
+	cached, err := c.load(path)
+	if err == nil && c.age(cached) <= c.MaxAge {
+		return cached.result()
+	}
+
+	if err := os.MkdirAll(c.Dir, 0o700); err != nil {
+		return icinga.RunCheck(ctx, check, 0)
+	}
+	lock, err := tryLock(path + ".lock")
+	if err != nil {
+		return icinga.RunCheck(ctx, check, 0)
+	}
+
+	if lock == nil {
+		// another process refreshes the result
+		if cached != nil && c.age(cached) <= c.MaxStale {
+			return c.stale(cached)
+		}
+		if lock, err = waitLock(ctx, path+".lock"); err != nil {
+			return icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("waiting for cached result: %s", err.Error())}
+		}
+	}
+	defer lock.unlock()
+
+	// the result may have been refreshed while waiting for the lock
+	if cached, err = c.load(path); err == nil && c.age(cached) <= c.MaxAge {
+		return cached.result()
+	}
+
+	start := time.Now()
+	result := icinga.RunCheck(ctx, check, 0)
+	if ctx.Err() == nil {
+		c.store(path, result, start)
+	}
+	return result
+}
+
+func (c *Cache) age(e *entry) time.Duration {
+	return time.Since(e.Time)
+}
+
+// stale returns the stale result with a marker in the message
+func (c *Cache) stale(e *entry) icinga.Result {
+	r := e.result()
+	r.Message = fmt.Sprintf("%s (stale result of %s)", r.Message, e.Time.Format(time.RFC3339))
+	return r
+}
+
+func (c *Cache) load(path string) (*entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e := new(entry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// store writes the result to a temporary file which replaces the cached result
+func (c *Cache) store(path string, r icinga.Result, t time.Time) error {
+	e := entry{Time: t, Code: r.Code, Message: r.Message, LongOutput: r.LongOutput}
+	for _, pd := range r.PerfData {
+		e.PerfData = append(e.PerfData, pd.String())
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(c.Dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func (e *entry) result() icinga.Result {
+	r := icinga.Result{Code: e.Code, Message: e.Message, LongOutput: e.LongOutput}
+	list, _ := perfdata.Parse(strings.Join(e.PerfData, " "))
+	r.PerfData = list
+	return r
+}
@@ -0,0 +1,150 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func counting(runs *int32) icinga.CheckFunc {
+	return func(ctx context.Context) icinga.Result {
+		n := atomic.AddInt32(runs, 1)
+		pd := perfdata.CreatePerformanceData("runs", float64(n), "c")
+		pd.SetWarning("10")
+		return icinga.Result{Code: icinga.ExitWarning, Message: "slow api", LongOutput: "details",
+			PerfData: []perfdata.PerformanceData{*pd}}
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("check_api", "-H", "a") != Key("check_api", "-H", "a") || Key("a", "b") == Key("ab") || len(Key()) != 64 {
+		t.Errorf("Unexpected keys")
+	}
+}
+
+func TestRunFresh(t *testing.T) {
+	var runs int32
+	c := CreateCache(t.TempDir(), time.Minute)
+	check := c.Wrap(Key("check_api"), counting(&runs))
+
+	first := check(context.Background())
+	second := check(context.Background())
+	if runs != 1 {
+		t.Errorf("Expecting one run, got %d", runs)
+	}
+	want := "WARNING - slow api | 'runs'=1c;10;;;\ndetails\n"
+	if first.String() != want || second.String() != want {
+		t.Errorf("Unexpected results %q %q", first.String(), second.String())
+	}
+}
+
+func TestRunExpired(t *testing.T) {
+	var runs int32
+	c := CreateCache(t.TempDir(), time.Minute)
+	key := Key("check_api")
+	c.store(filepath.Join(c.Dir, key+".json"), icinga.Result{Code: icinga.ExitOk, Message: "old"}, time.Now().Add(-2*time.Minute))
+
+	r := c.Run(context.Background(), key, counting(&runs))
+	if runs != 1 || r.Message != "slow api" {
+		t.Errorf("Expecting refreshed result, got %q after %d runs", r, runs)
+	}
+}
+
+func TestRunLocked(t *testing.T) {
+	var runs int32
+	c := CreateCache(t.TempDir(), time.Minute)
+	key := Key("check_api")
+	path := filepath.Join(c.Dir, key+".json")
+	checked := time.Now().Add(-2 * time.Minute)
+	c.store(path, icinga.Result{Code: icinga.ExitCritical, Message: "old"}, checked)
+
+	// another process refreshes the result
+	l, err := tryLock(path + ".lock")
+	if err != nil || l == nil {
+		t.Fatalf("Cannot lock: %v", err)
+	}
+
+	r := c.Run(context.Background(), key, counting(&runs))
+	if runs != 0 || r.Code != icinga.ExitCritical || r.Message != "old (stale result of "+checked.Format(time.RFC3339)+")" {
+		t.Errorf("Expecting stale result, got %q after %d runs", r, runs)
+	}
+
+	// a result exceeding MaxStale waits for the refreshed result
+	c.MaxStale = time.Minute
+	time.AfterFunc(100*time.Millisecond, func() {
+		c.store(path, icinga.Result{Code: icinga.ExitOk, Message: "refreshed"}, time.Now())
+		l.unlock()
+	})
+	r = c.Run(context.Background(), key, counting(&runs))
+	if runs != 0 || r.Code != icinga.ExitOk || r.Message != "refreshed" {
+		t.Errorf("Expecting refreshed result, got %q after %d runs", r, runs)
+	}
+}
+
+func TestRunWaitCancelled(t *testing.T) {
+	var runs int32
+	c := CreateCache(t.TempDir(), time.Minute)
+	key := Key("check_api")
+	l, _ := tryLock(filepath.Join(c.Dir, key+".json.lock"))
+	defer l.unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r := c.Run(ctx, key, counting(&runs))
+	if runs != 0 || r.Code != icinga.ExitUnknown || !strings.HasPrefix(r.Message, "waiting for cached result: ") {
+		t.Errorf("Unexpected result %q", r)
+	}
+}
+
+func TestRunConcurrent(t *testing.T) {
+	var runs int32
+	c := CreateCache(t.TempDir(), time.Minute)
+	slow := func(ctx context.Context) icinga.Result {
+		time.Sleep(50 * time.Millisecond)
+		return counting(&runs)(ctx)
+	}
+
+	done := make(chan icinga.Result)
+	for i := 0; i < 5; i++ {
+		go func() { done <- c.Run(context.Background(), Key("check_api"), slow) }()
+	}
+	for i := 0; i < 5; i++ {
+		if r := <-done; r.Message != "slow api" {
+			t.Errorf("Unexpected result %q", r)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("Expecting one run, got %d", runs)
+	}
+}
+
+func TestRunUnwritable(t *testing.T) {
+	var runs int32
+	c := CreateCache("/dev/null/cache", time.Minute)
+	r := c.Run(context.Background(), Key("check_api"), counting(&runs))
+	if runs != 1 || r.Message != "slow api" {
+		t.Errorf("Expecting uncached result, got %q", r)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build !(darwin || dragonfly || freebsd || illumos || linux || netbsd || openbsd)
+
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+
+package cache
+
+import "context"
+
+// lock does nothing, results are refreshed without locking on this platform
+type lock struct{}
+
+func tryLock(path string) (*lock, error) {
+	return &lock{}, nil
+}
+
+func waitLock(ctx context.Context, path string) (*lock, error) {
+	return &lock{}, nil
+}
+
+func (l *lock) unlock() {
+}
@@ -0,0 +1,119 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// Result of a check
+type Result struct {
+	Code       ExitCode
+	Message    string
+	LongOutput string
+	PerfData   []perfdata.PerformanceData
+}
+
+// CheckFunc runs a check, it should return when the context is done
+type CheckFunc func(ctx context.Context) Result
+
+// Print prints the result with the printer and returns its exit code
+func (r Result) Print(p *Printer) ExitCode {
+	return p.PrintWithLongOutput(r.Message, r.LongOutput, r.Code, r.PerfData)
+}
+
+// String returns the result as printed by the DefaultPrinter
+func (r Result) String() string {
+	return DefaultPrinter.Render(r.Message, r.LongOutput, r.Code, r.PerfData)
+}
+
+// RunCheck runs the check with a timeout, 0 means no timeout. A check exceeding the
+// timeout or panicking results in UNKNOWN.
+func RunCheck(ctx context.Context, check CheckFunc, timeout time.Duration) Result {
+	parent := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	done := make(chan Result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- Result{Code: ExitUnknown, Message: fmt.Sprintf("check failed: %v", r)}
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	select {
+	case r := <-done:
+		return r
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			// the duration is only known if the timeout fired and not the deadline of the parent
+			if timeout > 0 && parent.Err() == nil {
+				return Result{Code: ExitUnknown, Message: fmt.Sprintf("check timed out after %s", timeout)}
+			}
+			return Result{Code: ExitUnknown, Message: "check timed out"}
+		}
+		return Result{Code: ExitUnknown, Message: "check cancelled"}
+	}
+}
+
+// ExitCodeOf returns the exit code of a plugin exit status, statuses other than 0 to 3 are UNKNOWN
+func ExitCodeOf(status int) ExitCode {
+	if status < int(ExitOk) || status > int(ExitUnknown) {
+		return ExitUnknown
+	}
+	return ExitCode(status)
+}
+
+// ParseResult parses the output of a plugin exiting with the code. The first line is the
+// message, a prefix like "OK - " matching the code is removed. Performance data follows the
+// first "|" of the first line and of the long output. The result is returned along with an
+// error for invalid performance data, it contains all entries before the invalid one.
+func ParseResult(code ExitCode, output string) (Result, error) {
+	r := Result{Code: code}
+
+	output = strings.TrimRight(output, "\n")
+	first, longOutput, _ := strings.Cut(output, "\n")
+
+	message, perfData, _ := strings.Cut(first, "|")
+	r.Message = strings.TrimPrefix(strings.TrimSpace(message), code.String()+" - ")
+
+	longOutput, longPerfData, _ := strings.Cut(longOutput, "|")
+	r.LongOutput = strings.TrimRight(longOutput, " \n")
+
+	var err error
+	for _, s := range []string{perfData, strings.ReplaceAll(longPerfData, "\n", " ")} {
+		var list []perfdata.PerformanceData
+		list, err = perfdata.Parse(s)
+		r.PerfData = append(r.PerfData, list...)
+		if err != nil {
+			break
+		}
+	}
+
+	return r, err
+}
@@ -0,0 +1,138 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func TestResultPrint(t *testing.T) {
+	var buf bytes.Buffer
+	r := Result{
+		Code:       ExitWarning,
+		Message:    "slow",
+		LongOutput: "details",
+		PerfData:   []perfdata.PerformanceData{*perfdata.CreatePerformanceData("time", 2, "s")},
+	}
+
+	if code := r.Print(CreatePrinter(&buf)); code != ExitWarning {
+		t.Errorf("Expecting %s, got %s", ExitWarning, code)
+	}
+	expectOutput(t, &buf, "WARNING - slow | 'time'=2s;;;;\ndetails\n")
+
+	if r.String() != "WARNING - slow | 'time'=2s;;;;\ndetails\n" {
+		t.Errorf("Unexpected result string: %q", r.String())
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	r := RunCheck(context.Background(), func(ctx context.Context) Result {
+		return Result{Code: ExitOk, Message: "fine"}
+	}, time.Second)
+	if r.Code != ExitOk || r.Message != "fine" {
+		t.Errorf("Unexpected result: %s", r)
+	}
+
+	r = RunCheck(context.Background(), func(ctx context.Context) Result {
+		<-ctx.Done()
+		return Result{Code: ExitOk, Message: "too late"}
+	}, 10*time.Millisecond)
+	if r.Code != ExitUnknown || r.Message != "check timed out after 10ms" {
+		t.Errorf("Unexpected result: %s", r)
+	}
+
+	r = RunCheck(context.Background(), func(ctx context.Context) Result {
+		panic("boom")
+	}, 0)
+	if r.Code != ExitUnknown || r.Message != "check failed: boom" {
+		t.Errorf("Unexpected result: %s", r)
+	}
+
+	// the deadline of the parent context is exceeded without or before the timeout
+	for _, timeout := range []time.Duration{0, 10 * time.Second} {
+		deadline, cancelDeadline := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		r = RunCheck(deadline, func(ctx context.Context) Result {
+			<-ctx.Done()
+			return Result{Code: ExitOk, Message: "too late"}
+		}, timeout)
+		cancelDeadline()
+		if r.Code != ExitUnknown || r.Message != "check timed out" {
+			t.Errorf("Unexpected result with timeout %s: %s", timeout, r)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r = RunCheck(ctx, func(ctx context.Context) Result {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return Result{Code: ExitOk}
+	}, 0)
+	if r.Code != ExitUnknown || r.Message != "check cancelled" {
+		t.Errorf("Unexpected result: %s", r)
+	}
+}
+
+func TestExitCodeOf(t *testing.T) {
+	for status, code := range map[int]ExitCode{0: ExitOk, 1: ExitWarning, 2: ExitCritical, 3: ExitUnknown,
+		4: ExitUnknown, -1: ExitUnknown, 137: ExitUnknown} {
+		if ExitCodeOf(status) != code {
+			t.Errorf("Expecting %s for %d, got %s", code, status, ExitCodeOf(status))
+		}
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	r, err := ParseResult(ExitWarning, "WARNING - slow | time=2s;1;3\ndetails\nmore | size=5B\ncount=3\n")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if r.Code != ExitWarning || r.Message != "slow" || r.LongOutput != "details\nmore" || len(r.PerfData) != 3 {
+		t.Errorf("Unexpected result: %q", r)
+	}
+	if r.PerfData[0].String() != "'time'=2s;1;3;;" || r.PerfData[2].String() != "'count'=3;;;;" {
+		t.Errorf("Unexpected performance data: %v", r.PerfData)
+	}
+
+	r, err = ParseResult(ExitOk, "DISK OK - all fine")
+	if err != nil || r.Message != "DISK OK - all fine" || r.LongOutput != "" || len(r.PerfData) != 0 {
+		t.Errorf("Unexpected result: %q %v", r, err)
+	}
+
+	r, err = ParseResult(ExitCritical, "CRITICAL - down | a=1 b=x")
+	if err == nil || r.Message != "down" || len(r.PerfData) != 1 {
+		t.Errorf("Expecting result with valid performance data and error, got %q %v", r, err)
+	}
+}
+
+func TestParseResultRoundTrip(t *testing.T) {
+	r := Result{
+		Code:       ExitCritical,
+		Message:    "down",
+		LongOutput: "line 1\nline 2",
+		PerfData:   []perfdata.PerformanceData{*perfdata.CreatePerformanceData("db1::connections", 12, "")},
+	}
+	parsed, err := ParseResult(r.Code, r.String())
+	if err != nil || parsed.String() != r.String() {
+		t.Errorf("Unexpected round trip result: %q %v", parsed, err)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// files lists the generated files and their templates, {{name}} is replaced by the plugin name
+var files = []struct{ name, template string }{
+	{"go.mod", "go.mod.tmpl"},
+	{"main.go", "main.go.tmpl"},
+	{"main_test.go", "main_test.go.tmpl"},
+	{"Makefile", "Makefile.tmpl"},
+	{"{{name}}.conf", "command.conf.tmpl"},
+}
+
+var (
+	validName   = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	validMetric = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+)
+
+// Options of a generated plugin
+type Options struct {
+	// Name of the plugin binary, e.g. check_queue
+	Name string
+	// Module path of the plugin
+	Module      string
+	Description string
+	// Metric is the label of the performance data
+	Metric string
+	// Force overwrites existing files
+	Force bool
+}
+
+// data of the templates
+type data struct {
+	Options
+	// Command is the name of the CheckCommand, the name without "check_"
+	Command string
+	// Variable is the prefix of the custom variables
+	Variable string
+}
+
+// generate writes the plugin skeleton into the directory and returns the written files
+func generate(dir string, options Options) ([]string, error) {
+	if !validName.MatchString(options.Name) {
+		return nil, fmt.Errorf("invalid plugin name %q", options.Name)
+	}
+	if options.Module == "" {
+		options.Module = options.Name
+	}
+	if options.Metric == "" {
+		options.Metric = "value"
+	}
+	if !validMetric.MatchString(options.Metric) {
+		return nil, fmt.Errorf("invalid metric %q", options.Metric)
+	}
+	if strings.ContainsAny(options.Description, "\n\r") {
+		return nil, errors.New("description contains a line break")
+	}
+	if options.Description == "" {
+		options.Description = "measures " + options.Metric + " and evaluates it against the thresholds"
+	}
+	command := strings.TrimPrefix(options.Name, "check_")
+	d := data{Options: options, Command: command, Variable: strings.ReplaceAll(command, "-", "_")}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, f := range files {
+		path := filepath.Join(dir, strings.ReplaceAll(f.name, "{{name}}", options.Name))
+		if _, err := os.Stat(path); err == nil && !options.Force {
+			return written, fmt.Errorf("%s exists, use -force to overwrite it", path)
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return written, err
+		}
+
+		content, err := render(f.template, d)
+		if err != nil {
+			return written, err
+		}
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// render executes the template, Go sources are formatted
+func render(name string, d data) ([]byte, error) {
+	t, err := template.ParseFS(templates, "templates/"+name)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, d); err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(name, ".go.tmpl") {
+		return format.Source(b.Bytes())
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,105 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	written, err := generate(dir, Options{Name: "check_queue", Module: "example.com/check_queue", Metric: "messages"})
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if len(written) != 5 {
+		t.Errorf("Expecting 5 files, got %v", written)
+	}
+
+	for file, contents := range map[string][]string{
+		"go.mod":           {"module example.com/check_queue\n"},
+		"main.go":          {`var Version = "dev"`, `flag.NewFlagSet("check_queue"`, `perfdata.CreatePerformanceData("messages"`},
+		"main_test.go":     {`ExpectSummary("messages is 0")`},
+		"Makefile":         {`-X main.Version=$(VERSION)`, "check_queue:\n\t$(BUILD) -o check_queue .\n"},
+		"check_queue.conf": {`object CheckCommand "queue"`, `PluginDir + "/check_queue"`, `value = "$queue_warning$"`},
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Errorf("Unexpected error occured: %s", err.Error())
+			continue
+		}
+		for _, c := range contents {
+			if !strings.Contains(string(data), c) {
+				t.Errorf("Expecting %s to contain %q:\n%s", file, c, data)
+			}
+		}
+	}
+
+	if _, err := generate(dir, Options{Name: "check_queue"}); err == nil || !strings.HasSuffix(err.Error(), "exists, use -force to overwrite it") {
+		t.Errorf("Expecting error for existing files, got %v", err)
+	}
+	if _, err := generate(dir, Options{Name: "check_queue", Force: true}); err != nil {
+		t.Errorf("Unexpected error occured: %s", err.Error())
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	for _, options := range []Options{
+		{Name: ""},
+		{Name: "check queue"},
+		{Name: "../check"},
+		{Name: "check_queue", Metric: `a"b`},
+		{Name: "check_queue", Description: "a\nb"},
+	} {
+		if _, err := generate(t.TempDir(), options); err == nil {
+			t.Errorf("Expecting error for %+v", options)
+		}
+	}
+}
+
+// TestGeneratedPlugin builds and tests the generated plugin against this module
+func TestGeneratedPlugin(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping build of the generated plugin")
+	}
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not available")
+	}
+	root, _ := filepath.Abs(filepath.Join("..", ".."))
+
+	dir := t.TempDir()
+	if _, err := generate(dir, Options{Name: "check_queue", Module: "example.com/check_queue"}); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	f, _ := os.OpenFile(filepath.Join(dir, "go.mod"), os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString("\nrequire github.com/marshei/icinga_plugins v0.0.0\n\nreplace github.com/marshei/icinga_plugins => " + root + "\n")
+	f.Close()
+
+	for _, args := range [][]string{{"vet", "./..."}, {"test", "./..."}} {
+		cmd := exec.Command(goTool, args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOWORK=off")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("go %s failed: %s\n%s", args[0], err.Error(), output)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Version is set by the Makefile with -ldflags "-X main.Version=..."
+var Version = "dev"
+
+func main() {
+	var options Options
+	flag.StringVar(&options.Name, "name", "", "name of the plugin, e.g. check_queue")
+	flag.StringVar(&options.Module, "module", "", "module path of the plugin, defaults to the name")
+	flag.StringVar(&options.Description, "description", "", "description of the check")
+	flag.StringVar(&options.Metric, "metric", "value", "label of the performance data")
+	flag.BoolVar(&options.Force, "force", false, "overwrite existing files")
+	dir := flag.String("dir", "", "output directory, defaults to the name")
+	version := flag.Bool("version", false, "print the version")
+	flag.Parse()
+
+	if *version {
+		fmt.Println("icinga-plugin-gen " + Version)
+		return
+	}
+	if options.Name == "" {
+		fmt.Fprintln(os.Stderr, "missing -name")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *dir == "" {
+		*dir = options.Name
+	}
+
+	written, err := generate(*dir, options)
+	for _, path := range written {
+		fmt.Println("created " + path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	fmt.Printf("run \"go mod tidy\" and \"make\" in %s\n", filepath.Clean(*dir))
+}
@@ -0,0 +1,224 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package command
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+// Command runs an external plugin like check_disk
+type Command struct {
+	Path string
+	Args []string
+	// Env contains additional variables "key=value"
+	Env []string
+	// InheritEnv passes the environment of the current process to the plugin
+	InheritEnv bool
+	Dir        string
+	// Timeout kills the plugin and its children, 0 means no timeout
+	Timeout time.Duration
+}
+
+// Output of a plugin run
+type Output struct {
+	Result icinga.Result
+	Stdout string
+	Stderr string
+	// ExitStatus of the process, -1 if it was not started or terminated by a signal
+	ExitStatus int
+	Duration   time.Duration
+}
+
+// CreateCommand creates and returns a new Command for the plugin with the arguments
+// inheriting the environment with a timeout of 60 seconds
+func CreateCommand(path string, args ...string) *Command {
+	c := new(Command)
+	c.Path = path
+	c.Args = args
+	c.InheritEnv = true
+	c.Timeout = 60 * time.Second
+
+	return c
+}
+
+// CreateCommandLine creates a Command from a command line split by SplitCommandLine
+func CreateCommandLine(commandLine string) (*Command, error) {
+	args, err := SplitCommandLine(commandLine)
+	if err != nil {
+		return nil, err
+	}
+	if len(args) == 0 {
+		return nil, errors.New("empty command line")
+	}
+	return CreateCommand(args[0], args[1:]...), nil
+}
+
+// Check runs the plugin and returns its result, it can be used as icinga.CheckFunc
+func (c *Command) Check(ctx context.Context) icinga.Result {
+	return c.Run(ctx).Result
+}
+
+// Run runs the plugin and parses its output. Exit statuses other than 0 to 3, signals, timeouts
+// and plugins which cannot be started result in UNKNOWN. The standard error output is appended
+// to the long output.
+func (c *Command) Run(ctx context.Context) Output {
+	parent := ctx
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(c.Path, c.Args...)
+	cmd.Dir = c.Dir
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// a nil environment would inherit the environment of the current process
+	cmd.Env = append([]string{}, c.Env...)
+	if c.InheritEnv {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	prepare(cmd)
+
+	out := Output{ExitStatus: -1}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		out.Result = icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("cannot run %s: %s", c.Path, err.Error())}
+		return out
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		kill(cmd)
+		err = <-done
+	}
+	out.Duration = time.Since(start)
+	out.Stdout = stdout.String()
+	out.Stderr = stderr.String()
+	out.ExitStatus = cmd.ProcessState.ExitCode()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded && c.Timeout > 0 && parent.Err() == nil:
+		out.Result = icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("plugin timed out after %s", c.Timeout)}
+	case ctx.Err() == context.DeadlineExceeded:
+		// the deadline of the parent context was exceeded
+		out.Result = icinga.Result{Code: icinga.ExitUnknown, Message: "plugin timed out"}
+	case ctx.Err() != nil:
+		out.Result = icinga.Result{Code: icinga.ExitUnknown, Message: "plugin cancelled"}
+	case err != nil && !errors.As(err, &exitErr):
+		out.Result = icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("plugin failed: %s", err.Error())}
+	case out.ExitStatus < 0:
+		out.Result = c.parse(icinga.ExitUnknown, out)
+		out.Result.Message = fmt.Sprintf("plugin terminated (%s): %s", cmd.ProcessState.String(), out.Result.Message)
+	default:
+		out.Result = c.parse(icinga.ExitCodeOf(out.ExitStatus), out)
+		if out.ExitStatus > int(icinga.ExitUnknown) {
+			out.Result.Message = fmt.Sprintf("plugin exited with status %d: %s", out.ExitStatus, out.Result.Message)
+		}
+	}
+	return out
+}
+
+// parse parses the output, invalid performance data is reported in the long output
+func (c *Command) parse(code icinga.ExitCode, out Output) icinga.Result {
+	if strings.TrimSpace(out.Stdout) == "" {
+		out.Stdout = "(No output returned from plugin)"
+	}
+
+	r, err := icinga.ParseResult(code, out.Stdout)
+	var lines []string
+	if r.LongOutput != "" {
+		lines = append(lines, r.LongOutput)
+	}
+	if err != nil {
+		lines = append(lines, "invalid performance data: "+err.Error())
+	}
+	if stderr := strings.TrimRight(out.Stderr, "\n"); stderr != "" {
+		lines = append(lines, stderr)
+	}
+	r.LongOutput = strings.Join(lines, "\n")
+	return r
+}
+
+// SplitCommandLine splits a command line into arguments separated by spaces like a shell without
+// expansions. Single quotes preserve all characters, double quotes all but backslash escapes.
+func SplitCommandLine(commandLine string) ([]string, error) {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote byte
+
+	for i := 0; i < len(commandLine); i++ {
+		ch := commandLine[i]
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				arg.WriteByte(ch)
+			}
+		case ch == '\\':
+			if i+1 >= len(commandLine) {
+				return nil, errors.New("command line ends with a backslash")
+			}
+			i++
+			arg.WriteByte(commandLine[i])
+			inArg = true
+		case quote == '"':
+			if ch == '"' {
+				quote = 0
+			} else {
+				arg.WriteByte(ch)
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inArg = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteByte(ch)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote %c", quote)
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
@@ -0,0 +1,147 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package command
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+func shell(t *testing.T, script string) *Command {
+	t.Helper()
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	return CreateCommand(sh, "-c", script)
+}
+
+func TestRun(t *testing.T) {
+	c := shell(t, `echo "WARNING - disk $DISK | '/'=80%;70;90;0;100"; echo "details"; echo "warning: slow" >&2; exit 1`)
+	c.Env = []string{"DISK=full"}
+
+	out := c.Run(context.Background())
+	if out.ExitStatus != 1 || out.Stderr != "warning: slow\n" || !strings.HasPrefix(out.Stdout, "WARNING - disk full") {
+		t.Errorf("Unexpected output %+v", out)
+	}
+	r := out.Result
+	if r.Code != icinga.ExitWarning || r.Message != "disk full" || r.LongOutput != "details\nwarning: slow" ||
+		len(r.PerfData) != 1 || r.PerfData[0].String() != "'/'=80%;70;90;0;100" {
+		t.Errorf("Unexpected result %q", r)
+	}
+}
+
+func TestRunExitStatus(t *testing.T) {
+	for script, want := range map[string]string{
+		"exit 0":                     "OK - (No output returned from plugin)\n",
+		"echo critical; exit 2":      "CRITICAL - critical\n",
+		"echo broken; exit 4":        "UNKNOWN - plugin exited with status 4: broken\n",
+		"echo 'a=1 b=x'; exit 0":     "OK - a=1 b=x\n",
+		"echo 'ok | a=1 b=x'":        "OK - ok | 'a'=1;;;;\ninvalid performance data: invalid value of performance data b: invalid number \"x\"\n",
+		"echo gone; kill -9 $$":      "UNKNOWN - plugin terminated (signal: killed): gone\n",
+		"echo 'UNKNOWN - x'; exit 3": "UNKNOWN - x\n",
+	} {
+		if r := shell(t, script).Check(context.Background()); r.String() != want {
+			t.Errorf("Unexpected result of %q: %q", script, r.String())
+		}
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	c := shell(t, "sleep 10 & sleep 10; echo done")
+	c.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	out := c.Run(context.Background())
+	if out.Result.Code != icinga.ExitUnknown || out.Result.Message != "plugin timed out after 50ms" {
+		t.Errorf("Unexpected result %q", out.Result)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Plugin and its children were not killed")
+	}
+
+	// the deadline of the parent context is shorter than the timeout or there is no timeout
+	for _, timeout := range []time.Duration{0, 10 * time.Second} {
+		deadline, cancelDeadline := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		c.Timeout = timeout
+		if r := c.Check(deadline); r.Code != icinga.ExitUnknown || r.Message != "plugin timed out" {
+			t.Errorf("Unexpected result with timeout %s: %q", timeout, r)
+		}
+		cancelDeadline()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	if r := shell(t, "sleep 10").Check(ctx); r.Code != icinga.ExitUnknown || r.Message != "plugin cancelled" {
+		t.Errorf("Unexpected result %q", r)
+	}
+}
+
+func TestRunNotFound(t *testing.T) {
+	r := CreateCommand("/nonexistent/check_disk").Check(context.Background())
+	if r.Code != icinga.ExitUnknown || !strings.HasPrefix(r.Message, "cannot run /nonexistent/check_disk: ") {
+		t.Errorf("Unexpected result %q", r)
+	}
+}
+
+func TestRunEnv(t *testing.T) {
+	t.Setenv("INHERITED", "yes")
+	c := shell(t, `echo "inherited=$INHERITED"`)
+	if r := c.Check(context.Background()); r.Message != "inherited=yes" {
+		t.Errorf("Unexpected result %q", r)
+	}
+
+	c.InheritEnv = false
+	if r := c.Check(context.Background()); r.Message != "inherited=" {
+		t.Errorf("Unexpected result %q", r)
+	}
+}
+
+func TestSplitCommandLine(t *testing.T) {
+	for commandLine, want := range map[string][]string{
+		`check_disk -w 20% -c 10%`:     {"check_disk", "-w", "20%", "-c", "10%"},
+		`  check_http  -u '/a b' `:     {"check_http", "-u", "/a b"},
+		`check "a \"b\" c" 'd\e' f\ g`: {"check", `a "b" c`, `d\e`, "f g"},
+		`check '' ""`:                  {"check", "", ""},
+		`check -a'b'"c"`:               {"check", "-abc"},
+		``:                             nil,
+	} {
+		args, err := SplitCommandLine(commandLine)
+		if err != nil || strings.Join(args, "|") != strings.Join(want, "|") || len(args) != len(want) {
+			t.Errorf("Unexpected arguments of %s: %q %v", commandLine, args, err)
+		}
+	}
+
+	for _, commandLine := range []string{`check 'a`, `check "a`, `check a\`} {
+		if _, err := SplitCommandLine(commandLine); err == nil {
+			t.Errorf("Expecting error for %s", commandLine)
+		}
+	}
+
+	if _, err := CreateCommandLine("  "); err == nil || err.Error() != "empty command line" {
+		t.Errorf("Expecting empty command line error, got %v", err)
+	}
+	c, err := CreateCommandLine("/usr/lib/nagios/plugins/check_load -w 5")
+	if err != nil || c.Path != "/usr/lib/nagios/plugins/check_load" || len(c.Args) != 2 {
+		t.Errorf("Unexpected command %+v %v", c, err)
+	}
+}
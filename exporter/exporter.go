@@ -0,0 +1,88 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package exporter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// Exporter runs a check on each scrape and exposes its result as Prometheus metrics
+type Exporter struct {
+	Check   icinga.CheckFunc
+	Host    string
+	Name    string
+	Timeout time.Duration
+}
+
+// CreateExporter creates and returns a new Exporter for the check identified by host and name
+func CreateExporter(check icinga.CheckFunc, host string, name string, timeout time.Duration) *Exporter {
+	e := new(Exporter)
+	e.Check = check
+	e.Host = host
+	e.Name = name
+	e.Timeout = timeout
+
+	return e
+}
+
+// ServeHTTP runs the check and writes the performance data as encoded by perfdata.EncodePrometheus,
+// i.e. entries with the unit c as counter and all others as gauge, along with icinga_check_state,
+// the exit code of the check, and icinga_check_duration_seconds
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	result := icinga.RunCheck(r.Context(), e.Check, e.Timeout)
+	duration := time.Since(start)
+
+	source := perfdata.Source{Host: e.Host, Check: e.Name}
+	labels := "{host=" + perfdata.PrometheusLabelValue(e.Host) + ",check=" + perfdata.PrometheusLabelValue(e.Name) + "}"
+
+	var b strings.Builder
+	b.WriteString(perfdata.EncodePrometheus(result.PerfData, source))
+	b.WriteString("# HELP icinga_check_state Exit code of the check: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN\n")
+	b.WriteString("# TYPE icinga_check_state gauge\n")
+	b.WriteString(fmt.Sprintf("icinga_check_state%s %d\n", labels, int(result.Code)))
+	b.WriteString("# TYPE icinga_check_duration_seconds gauge\n")
+	b.WriteString(fmt.Sprintf("icinga_check_duration_seconds%s %s\n", labels,
+		strconv.FormatFloat(duration.Seconds(), 'g', -1, 64)))
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(b.String()))
+}
+
+// Handler returns a handler serving the metrics at /metrics
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	return mux
+}
+
+// ListenAndServe serves the metrics at /metrics on the given address
+func (e *Exporter) ListenAndServe(addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           e.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return server.ListenAndServe()
+}
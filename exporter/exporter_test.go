@@ -0,0 +1,132 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func scrape(t *testing.T, e *Exporter) string {
+	server := httptest.NewServer(e.Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %s", ct)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func expectMetric(t *testing.T, body string, line string) {
+	t.Helper()
+	if !strings.Contains(body, line+"\n") {
+		t.Errorf("Expecting metric %s in:\n%s", line, body)
+	}
+}
+
+func TestExporter(t *testing.T) {
+	runs := 0
+	check := func(ctx context.Context) icinga.Result {
+		runs++
+		return icinga.Result{
+			Code:    icinga.ExitWarning,
+			Message: "load high",
+			PerfData: []perfdata.PerformanceData{
+				*perfdata.CreatePerformanceData("load1", 4.5, ""),
+				*perfdata.CreatePerformanceData("time", 20, "ms"),
+			},
+		}
+	}
+	e := CreateExporter(check, "web1", "load", time.Second)
+
+	body := scrape(t, e)
+	expectMetric(t, body, `icinga_perfdata_load1{host="web1",check="load"} 4.5`)
+	expectMetric(t, body, `icinga_perfdata_time_seconds{host="web1",check="load"} 0.02`)
+	expectMetric(t, body, `icinga_check_state{host="web1",check="load"} 1`)
+	if !strings.Contains(body, `icinga_check_duration_seconds{host="web1",check="load"} `) {
+		t.Errorf("Expecting the check duration in:\n%s", body)
+	}
+
+	scrape(t, e)
+	if runs != 2 {
+		t.Errorf("Expecting a check run per scrape, got %d runs", runs)
+	}
+}
+
+func TestExporterTimeout(t *testing.T) {
+	check := func(ctx context.Context) icinga.Result {
+		<-ctx.Done()
+		return icinga.Result{Code: icinga.ExitOk}
+	}
+	e := CreateExporter(check, "web1", "slow", 10*time.Millisecond)
+
+	body := scrape(t, e)
+	expectMetric(t, body, `icinga_check_state{host="web1",check="slow"} 3`)
+}
+
+func TestExporterNotFound(t *testing.T) {
+	server := httptest.NewServer(CreateExporter(nil, "", "", 0).Handler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/other")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expecting status %d, got %d", http.StatusNotFound, resp.StatusCode)
+	}
+}
+
+func TestExporterNamespace(t *testing.T) {
+	check := func(ctx context.Context) icinga.Result {
+		return icinga.Result{
+			Code: icinga.ExitOk,
+			PerfData: []perfdata.PerformanceData{
+				*perfdata.CreatePerformanceData("check_duration", 2, "s"),
+				*perfdata.CreatePerformanceData("check_state", 0, ""),
+			},
+		}
+	}
+
+	body := scrape(t, CreateExporter(check, "web1", "load", time.Second))
+	for _, name := range []string{"icinga_check_duration_seconds", "icinga_check_state",
+		"icinga_perfdata_check_duration_seconds", "icinga_perfdata_check_state"} {
+		if n := strings.Count(body, "# TYPE "+name+" "); n != 1 {
+			t.Errorf("Expecting one type of %s, got %d in:\n%s", name, n, body)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"html"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// SubCheck is the result of a part of a check shown in the long output
+type SubCheck struct {
+	Name    string
+	Code    ExitCode
+	Message string
+}
+
+// LongOutput builds the long output either as HTML rendered by Icinga Web 2 or as plain text
+type LongOutput struct {
+	HTML  bool
+	lines []string
+}
+
+// CreateLongOutput creates and returns a new LongOutput, plain text is used if html is false
+func CreateLongOutput(html bool) *LongOutput {
+	lo := new(LongOutput)
+	lo.HTML = html
+
+	return lo
+}
+
+// String returns the long output to be passed to PrintWithLongOutput
+func (lo *LongOutput) String() string {
+	return strings.Join(lo.lines, "\n")
+}
+
+// Text adds a line of text
+func (lo *LongOutput) Text(text string) {
+	if lo.HTML {
+		text = html.EscapeString(text)
+	}
+	lo.lines = append(lo.lines, text)
+}
+
+// Badge returns the state of the exit code as badge, in plain text as "[OK]"
+// which Icinga Web 2 also shows as state
+func (lo *LongOutput) Badge(code ExitCode) string {
+	if lo.HTML {
+		return `<span class="badge state-` + strings.ToLower(code.String()) + `">` + code.String() + `</span>`
+	}
+	return "[" + code.String() + "]"
+}
+
+// Link returns a link to the URL, only http and https URLs are linked
+func (lo *LongOutput) Link(text string, link string) string {
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		if lo.HTML {
+			return html.EscapeString(text)
+		}
+		return text
+	}
+	if lo.HTML {
+		return `<a href="` + html.EscapeString(u.String()) + `" target="_blank">` + html.EscapeString(text) + `</a>`
+	}
+	return text + " (" + u.String() + ")"
+}
+
+// Table adds a table with a header row, in plain text the columns are aligned with spaces.
+// The cells are escaped, use RawTable for cells containing badges or links.
+func (lo *LongOutput) Table(header []string, rows [][]string) {
+	if lo.HTML {
+		header = escapeCells(header)
+		escaped := make([][]string, 0, len(rows))
+		for _, row := range rows {
+			escaped = append(escaped, escapeCells(row))
+		}
+		rows = escaped
+	}
+	lo.RawTable(header, rows)
+}
+
+// RawTable adds a table like Table but without escaping the cells
+func (lo *LongOutput) RawTable(header []string, rows [][]string) {
+	if lo.HTML {
+		var b strings.Builder
+		b.WriteString("<table>")
+		if len(header) > 0 {
+			b.WriteString("<tr>")
+			for _, h := range header {
+				b.WriteString("<th>" + h + "</th>")
+			}
+			b.WriteString("</tr>")
+		}
+		for _, row := range rows {
+			b.WriteString("<tr>")
+			for _, c := range row {
+				b.WriteString("<td>" + c + "</td>")
+			}
+			b.WriteString("</tr>")
+		}
+		b.WriteString("</table>")
+		lo.lines = append(lo.lines, b.String())
+		return
+	}
+
+	all := rows
+	if len(header) > 0 {
+		all = append([][]string{header}, rows...)
+	}
+	var widths []int
+	for _, row := range all {
+		for i, c := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			if n := utf8.RuneCountInString(c); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	for _, row := range all {
+		var b strings.Builder
+		for i, c := range row {
+			if i > 0 {
+				b.WriteString("  ")
+			}
+			b.WriteString(c)
+			if i < len(row)-1 {
+				b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(c)))
+			}
+		}
+		lo.lines = append(lo.lines, b.String())
+	}
+}
+
+// SubChecks adds a table of the sub-checks with their states
+func (lo *LongOutput) SubChecks(checks []SubCheck) {
+	var rows [][]string
+	for _, c := range checks {
+		name, message := c.Name, c.Message
+		if lo.HTML {
+			name, message = html.EscapeString(name), html.EscapeString(message)
+		}
+		rows = append(rows, []string{lo.Badge(c.Code), name, message})
+	}
+	lo.RawTable(nil, rows)
+}
+
+func escapeCells(cells []string) []string {
+	escaped := make([]string, 0, len(cells))
+	for _, c := range cells {
+		escaped = append(escaped, html.EscapeString(c))
+	}
+	return escaped
+}
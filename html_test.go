@@ -0,0 +1,87 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"bytes"
+	"testing"
+)
+
+func expectLongOutput(t *testing.T, lo *LongOutput, want string) {
+	t.Helper()
+	if lo.String() != want {
+		t.Errorf("Long output was incorrect, got: %q, want: %q.", lo.String(), want)
+	}
+}
+
+var testSubChecks = []SubCheck{
+	{Name: "disk /", Code: ExitOk, Message: "20% used"},
+	{Name: "disk <tmp>", Code: ExitCritical, Message: "99% used"},
+}
+
+func TestLongOutputHTML(t *testing.T) {
+	lo := CreateLongOutput(true)
+	lo.Text("a <b> & c")
+	lo.Table([]string{"name", "value"}, [][]string{{"<x>", "1"}})
+	lo.SubChecks(testSubChecks)
+	expectLongOutput(t, lo, "a &lt;b&gt; &amp; c\n"+
+		"<table><tr><th>name</th><th>value</th></tr><tr><td>&lt;x&gt;</td><td>1</td></tr></table>\n"+
+		"<table>"+
+		`<tr><td><span class="badge state-ok">OK</span></td><td>disk /</td><td>20% used</td></tr>`+
+		`<tr><td><span class="badge state-critical">CRITICAL</span></td><td>disk &lt;tmp&gt;</td><td>99% used</td></tr>`+
+		"</table>")
+}
+
+func TestLongOutputPlainText(t *testing.T) {
+	lo := CreateLongOutput(false)
+	lo.Text("a <b> & c")
+	lo.Table([]string{"name", "value"}, [][]string{{"<x>", "1"}, {"äöü-long", "22"}})
+	lo.SubChecks(testSubChecks)
+	expectLongOutput(t, lo, "a <b> & c\n"+
+		"name      value\n"+
+		"<x>       1\n"+
+		"äöü-long  22\n"+
+		"[OK]        disk /      20% used\n"+
+		"[CRITICAL]  disk <tmp>  99% used")
+}
+
+func TestLongOutputLink(t *testing.T) {
+	h := CreateLongOutput(true)
+	p := CreateLongOutput(false)
+
+	link := h.Link("docs & more", "https://example.com/a?b=1&c=2")
+	want := `<a href="https://example.com/a?b=1&amp;c=2" target="_blank">docs &amp; more</a>`
+	if link != want {
+		t.Errorf("Link was incorrect, got: %s, want: %s.", link, want)
+	}
+	if link = p.Link("docs", "https://example.com/"); link != "docs (https://example.com/)" {
+		t.Errorf("Link was incorrect, got: %s", link)
+	}
+	if link = h.Link("<x>", "javascript:alert(1)"); link != "&lt;x&gt;" {
+		t.Errorf("Link was incorrect, got: %s", link)
+	}
+}
+
+func TestPrintLongOutput(t *testing.T) {
+	var buf bytes.Buffer
+	p := CreatePrinter(&buf)
+
+	lo := CreateLongOutput(false)
+	lo.SubChecks(testSubChecks[1:])
+	p.PrintWithLongOutput("1 of 1 disks critical", lo.String(), ExitCritical, nil)
+	expectOutput(t, &buf, "CRITICAL - 1 of 1 disks critical\n[CRITICAL]  disk <tmp>  99% used\n")
+}
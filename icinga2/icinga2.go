@@ -0,0 +1,250 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga2
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// DefaultURL of the Icinga 2 API on the local node
+const DefaultURL = "https://localhost:5665"
+
+var attrs = []string{
+	"display_name", "state", "state_type", "last_check", "last_check_result",
+	"acknowledgement", "downtime_depth",
+}
+
+// Object is the state of a host or, if Service is set, a service
+type Object struct {
+	Host        string
+	Service     string
+	DisplayName string
+	// Result of the last check, hosts are OK if UP and CRITICAL if DOWN
+	Result icinga.Result
+	// Checked is false for pending objects, their result is UNKNOWN
+	Checked      bool
+	Hard         bool
+	Acknowledged bool
+	InDowntime   bool
+	LastCheck    time.Time
+}
+
+// Name returns "host" or "host!service" as used by the API
+func (o Object) Name() string {
+	if o.Service == "" {
+		return o.Host
+	}
+	return o.Host + "!" + o.Service
+}
+
+// Client reads object states from the Icinga 2 API
+type Client struct {
+	URL        string
+	Username   string
+	Password   string
+	HTTPClient *http.Client
+}
+
+// CreateClient creates and returns a new Client for the API at the URL, e.g. DefaultURL,
+// with a timeout of 10 seconds. The TLS configuration should trust the Icinga 2 CA.
+func CreateClient(url string, username string, password string, tlsConfig *tls.Config) *Client {
+	c := new(Client)
+	c.URL = strings.TrimSuffix(url, "/")
+	c.Username = username
+	c.Password = password
+	c.HTTPClient = &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: http.ProxyFromEnvironment},
+	}
+
+	return c
+}
+
+// Services returns the services matching the filter expression, e.g.
+// `match(pattern, service.name)` with pattern in vars. An empty filter returns all services.
+func (c *Client) Services(ctx context.Context, filter string, vars map[string]interface{}) ([]Object, error) {
+	return c.objects(ctx, "services", filter, vars)
+}
+
+// Hosts returns the hosts matching the filter expression, e.g. `"web" in host.groups`.
+// An empty filter returns all hosts.
+func (c *Client) Hosts(ctx context.Context, filter string, vars map[string]interface{}) ([]Object, error) {
+	return c.objects(ctx, "hosts", filter, vars)
+}
+
+type query struct {
+	Filter     string                 `json:"filter,omitempty"`
+	FilterVars map[string]interface{} `json:"filter_vars,omitempty"`
+	Attrs      []string               `json:"attrs"`
+}
+
+type checkResult struct {
+	State           float64           `json:"state"`
+	Output          string            `json:"output"`
+	PerformanceData []json.RawMessage `json:"performance_data"`
+}
+
+type objectAttrs struct {
+	DisplayName     string       `json:"display_name"`
+	State           float64      `json:"state"`
+	StateType       float64      `json:"state_type"`
+	LastCheck       float64      `json:"last_check"`
+	LastCheckResult *checkResult `json:"last_check_result"`
+	Acknowledgement float64      `json:"acknowledgement"`
+	DowntimeDepth   float64      `json:"downtime_depth"`
+}
+
+type response struct {
+	Results []struct {
+		Name  string      `json:"name"`
+		Type  string      `json:"type"`
+		Attrs objectAttrs `json:"attrs"`
+	} `json:"results"`
+}
+
+type errorResponse struct {
+	Status string `json:"status"`
+}
+
+func (c *Client) objects(ctx context.Context, kind string, filter string, vars map[string]interface{}) ([]Object, error) {
+	body, err := json.Marshal(query{Filter: filter, FilterVars: vars, Attrs: attrs})
+	if err != nil {
+		return nil, err
+	}
+
+	// filters are sent in the body of a POST overriding the method as recommended by Icinga 2
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/v1/objects/"+kind, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-HTTP-Method-Override", http.MethodGet)
+	req.SetBasicAuth(c.Username, c.Password)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var e errorResponse
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if json.Unmarshal(msg, &e) == nil && e.Status != "" {
+			return nil, fmt.Errorf("api request failed: %s %s", resp.Status, e.Status)
+		}
+		return nil, fmt.Errorf("api request failed: %s", resp.Status)
+	}
+
+	var r response
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("invalid api response: %s", err.Error())
+	}
+
+	var objects []Object
+	for _, result := range r.Results {
+		objects = append(objects, toObject(result.Type, result.Name, result.Attrs))
+	}
+	return objects, nil
+}
+
+// toObject maps the attributes, invalid performance data accepted by Icinga 2 is skipped
+func toObject(kind string, name string, a objectAttrs) Object {
+	o := Object{
+		DisplayName:  a.DisplayName,
+		Hard:         a.StateType == 1,
+		Acknowledged: a.Acknowledgement > 0,
+		InDowntime:   a.DowntimeDepth > 0,
+	}
+	if kind == "Service" {
+		o.Host, o.Service, _ = strings.Cut(name, "!")
+	} else {
+		o.Host = name
+	}
+	if a.LastCheck > 0 {
+		sec, frac := math.Modf(a.LastCheck)
+		o.LastCheck = time.Unix(int64(sec), int64(frac*1e9))
+	}
+
+	if a.LastCheckResult == nil {
+		o.Result = icinga.Result{Code: icinga.ExitUnknown, Message: "pending"}
+		return o
+	}
+	o.Checked = true
+
+	code := icinga.ExitCodeOf(int(a.LastCheckResult.State))
+	if kind == "Host" {
+		// hosts are UP for OK and WARNING check results
+		if code == icinga.ExitWarning {
+			code = icinga.ExitOk
+		} else if code != icinga.ExitOk {
+			code = icinga.ExitCritical
+		}
+	}
+
+	// the output does not include the performance data
+	output := strings.TrimRight(a.LastCheckResult.Output, "\n")
+	message, longOutput, _ := strings.Cut(output, "\n")
+	o.Result = icinga.Result{
+		Code:       code,
+		Message:    strings.TrimPrefix(strings.TrimSpace(message), code.String()+" - "),
+		LongOutput: longOutput,
+	}
+	for _, raw := range a.LastCheckResult.PerformanceData {
+		var s string
+		if json.Unmarshal(raw, &s) != nil {
+			continue
+		}
+		list, _ := perfdata.Parse(s)
+		o.Result.PerfData = append(o.Result.PerfData, list...)
+	}
+	return o
+}
+
+// StateCounts returns the number of objects per state as performance data labeled ok, warning,
+// critical, unknown and total, e.g. to evaluate thresholds like "critical/total*100" with
+// thresholds.EvaluateExpressions
+func StateCounts(objects []Object) []perfdata.PerformanceData {
+	counts := make(map[icinga.ExitCode]int)
+	for _, o := range objects {
+		counts[o.Result.Code]++
+	}
+
+	var list []perfdata.PerformanceData
+	for _, code := range []icinga.ExitCode{icinga.ExitOk, icinga.ExitWarning, icinga.ExitCritical, icinga.ExitUnknown} {
+		pd := perfdata.CreatePerformanceData(strings.ToLower(code.String()), float64(counts[code]), "")
+		pd.SetMinimumValue(0)
+		pd.SetMaximumValue(float64(len(objects)))
+		list = append(list, *pd)
+	}
+	pd := perfdata.CreatePerformanceData("total", float64(len(objects)), "")
+	pd.SetMinimumValue(0)
+	return append(list, *pd)
+}
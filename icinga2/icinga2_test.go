@@ -0,0 +1,185 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga2
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/thresholds"
+)
+
+const servicesResponse = `{"results": [
+	{"name": "web1!http", "type": "Service", "attrs": {"display_name": "HTTP", "state": 2.0, "state_type": 1.0,
+		"last_check": 1700000000.5, "acknowledgement": 1.0, "downtime_depth": 0.0,
+		"last_check_result": {"state": 2.0, "exit_status": 2.0, "output": "CRITICAL - slow\ndetails\n",
+			"performance_data": ["time=2.5s;1;2;0", "'size'=10B", "invalid"]}}, "joins": {}, "meta": {}},
+	{"name": "web2!http", "type": "Service", "attrs": {"display_name": "HTTP", "state": 0.0, "state_type": 0.0,
+		"last_check": 1700000000.0, "acknowledgement": 0.0, "downtime_depth": 1.0,
+		"last_check_result": {"state": 0.0, "exit_status": 0.0, "output": "HTTP OK", "performance_data": []}}},
+	{"name": "web3!http", "type": "Service", "attrs": {"display_name": "HTTP", "state": 3.0, "state_type": 1.0,
+		"last_check": 0.0, "acknowledgement": 0.0, "downtime_depth": 0.0, "last_check_result": null}}
+]}`
+
+const hostsResponse = `{"results": [
+	{"name": "web1", "type": "Host", "attrs": {"display_name": "Web 1", "state": 0.0, "state_type": 1.0,
+		"last_check_result": {"state": 1.0, "output": "PING WARNING - slow", "performance_data": []}}},
+	{"name": "web2", "type": "Host", "attrs": {"display_name": "Web 2", "state": 1.0, "state_type": 1.0,
+		"last_check_result": {"state": 3.0, "output": "unreachable", "performance_data": null}}}
+]}`
+
+// api is a local stand-in for the Icinga 2 API
+func api(t *testing.T) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "root" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.Header.Get("X-HTTP-Method-Override") != http.MethodGet {
+			t.Errorf("Unexpected request method %s", r.Method)
+		}
+
+		var q query
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &q); err != nil {
+			t.Errorf("Invalid request: %s", err.Error())
+		}
+
+		switch r.URL.Path {
+		case "/v1/objects/services":
+			if q.Filter != "match(pattern, service.name)" || q.FilterVars["pattern"] != "http*" {
+				t.Errorf("Unexpected filter %+v", q)
+			}
+			io.WriteString(w, servicesResponse)
+		case "/v1/objects/hosts":
+			if q.Filter != "" || len(q.FilterVars) != 0 {
+				t.Errorf("Unexpected filter %+v", q)
+			}
+			io.WriteString(w, hostsResponse)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error": 404.0, "status": "The requested path 'v1/objects/unknown' could not be found."}`)
+		}
+	}))
+}
+
+func testClient(server *httptest.Server, password string) *Client {
+	c := CreateClient(server.URL, "root", password, nil)
+	c.HTTPClient = server.Client()
+	return c
+}
+
+func TestServices(t *testing.T) {
+	server := api(t)
+	defer server.Close()
+
+	services, err := testClient(server, "secret").Services(context.Background(), "match(pattern, service.name)",
+		map[string]interface{}{"pattern": "http*"})
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if len(services) != 3 {
+		t.Fatalf("Expecting 3 services, got %d", len(services))
+	}
+
+	s := services[0]
+	if s.Name() != "web1!http" || s.Host != "web1" || s.Service != "http" || s.DisplayName != "HTTP" ||
+		!s.Checked || !s.Hard || !s.Acknowledged || s.InDowntime ||
+		!s.LastCheck.Equal(time.Unix(1700000000, 500000000)) {
+		t.Errorf("Unexpected service %+v", s)
+	}
+	if s.Result.Code != icinga.ExitCritical || s.Result.Message != "slow" || s.Result.LongOutput != "details" ||
+		len(s.Result.PerfData) != 2 || s.Result.PerfData[0].String() != "'time'=2.5s;1;2;0;" {
+		t.Errorf("Unexpected result %q", s.Result)
+	}
+
+	if services[1].Result.Code != icinga.ExitOk || services[1].Result.Message != "HTTP OK" || !services[1].InDowntime {
+		t.Errorf("Unexpected service %+v", services[1])
+	}
+	if services[2].Checked || services[2].Result.Code != icinga.ExitUnknown || services[2].Result.Message != "pending" {
+		t.Errorf("Unexpected pending service %+v", services[2])
+	}
+}
+
+func TestHosts(t *testing.T) {
+	server := api(t)
+	defer server.Close()
+
+	hosts, err := testClient(server, "secret").Hosts(context.Background(), "", nil)
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if len(hosts) != 2 || hosts[0].Name() != "web1" || hosts[0].Result.Code != icinga.ExitOk ||
+		hosts[0].Result.Message != "PING WARNING - slow" || hosts[1].Result.Code != icinga.ExitCritical {
+		t.Errorf("Unexpected hosts %+v", hosts)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	server := api(t)
+	defer server.Close()
+
+	_, err := testClient(server, "wrong").Hosts(context.Background(), "", nil)
+	if err == nil || err.Error() != "api request failed: 401 Unauthorized" {
+		t.Errorf("Expecting unauthorized error, got %v", err)
+	}
+
+	_, err = testClient(server, "secret").objects(context.Background(), "unknown", "", nil)
+	if err == nil || err.Error() != "api request failed: 404 Not Found The requested path 'v1/objects/unknown' could not be found." {
+		t.Errorf("Expecting not found error, got %v", err)
+	}
+
+	// the certificate of the test server is not trusted
+	c := CreateClient(server.URL, "root", "secret", nil)
+	if _, err = c.Hosts(context.Background(), "", nil); err == nil {
+		t.Errorf("Expecting certificate error")
+	}
+}
+
+func TestStateCounts(t *testing.T) {
+	server := api(t)
+	defer server.Close()
+
+	services, _ := testClient(server, "secret").Services(context.Background(), "match(pattern, service.name)",
+		map[string]interface{}{"pattern": "http*"})
+	counts := StateCounts(services)
+
+	var s []string
+	for _, pd := range counts {
+		s = append(s, pd.String())
+	}
+	want := []string{"'ok'=1;;;0;3", "'warning'=0;;;0;3", "'critical'=1;;;0;3", "'unknown'=1;;;0;3", "'total'=3;;;0;"}
+	for i := range want {
+		if i >= len(s) || s[i] != want[i] {
+			t.Errorf("Unexpected state counts %v", s)
+			break
+		}
+	}
+
+	// more than 30% critical services
+	critical, _ := thresholds.ParseThresholdList("critical/total*100,30")
+	code, _, err := thresholds.EvaluateExpressions(nil, critical, counts)
+	if err != nil || code != icinga.ExitCritical {
+		t.Errorf("Expecting CRITICAL, got %s %v", code, err)
+	}
+}
@@ -0,0 +1,204 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icingatest
+
+import (
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// DefaultTimeout of the checks run by RunCheck
+var DefaultTimeout = 10 * time.Second
+
+// UpdateGoldenEnv is the environment variable which rewrites golden files if set to 1
+const UpdateGoldenEnv = "UPDATE_GOLDEN"
+
+// Run is the result of a check under test
+type Run struct {
+	t      testing.TB
+	Result icinga.Result
+	// Output as printed by the plugin
+	Output string
+}
+
+// RunCheck runs the check with DefaultTimeout
+func RunCheck(t testing.TB, check icinga.CheckFunc) *Run {
+	t.Helper()
+	r := icinga.RunCheck(context.Background(), check, DefaultTimeout)
+	return &Run{t: t, Result: r, Output: r.String()}
+}
+
+// Capture runs a function printing with the package level print functions of icinga to stdout,
+// e.g. the main function of a plugin, and parses the printed output
+func Capture(t testing.TB, f func() icinga.ExitCode) *Run {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Cannot capture output: %s", err.Error())
+	}
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		output <- string(data)
+	}()
+
+	var code icinga.ExitCode
+	func() {
+		defer func() {
+			os.Stdout = stdout
+			writer.Close()
+		}()
+		code = f()
+	}()
+
+	run := &Run{t: t, Output: <-output}
+	reader.Close()
+	run.Result, err = icinga.ParseResult(code, run.Output)
+	if err != nil {
+		t.Errorf("Invalid performance data: %s", err.Error())
+	}
+	return run
+}
+
+// ExpectCode checks the exit code
+func (r *Run) ExpectCode(code icinga.ExitCode) *Run {
+	r.t.Helper()
+	if r.Result.Code != code {
+		r.t.Errorf("Expecting exit code %s, got %s: %s", code, r.Result.Code, r.Result.Message)
+	}
+	return r
+}
+
+// ExpectSummary checks the message of the summary line
+func (r *Run) ExpectSummary(message string) *Run {
+	r.t.Helper()
+	if r.Result.Message != message {
+		r.t.Errorf("Expecting summary %q, got %q", message, r.Result.Message)
+	}
+	return r
+}
+
+// ExpectSummaryContains checks that the message of the summary line contains the string
+func (r *Run) ExpectSummaryContains(s string) *Run {
+	r.t.Helper()
+	if !strings.Contains(r.Result.Message, s) {
+		r.t.Errorf("Expecting summary containing %q, got %q", s, r.Result.Message)
+	}
+	return r
+}
+
+// ExpectLongOutput checks the long output without trailing line breaks
+func (r *Run) ExpectLongOutput(longOutput string) *Run {
+	r.t.Helper()
+	if got := strings.TrimRight(r.Result.LongOutput, "\n"); got != strings.TrimRight(longOutput, "\n") {
+		r.t.Errorf("Expecting long output %q, got %q", longOutput, got)
+	}
+	return r
+}
+
+// ExpectLongOutputContains checks that the long output contains the string
+func (r *Run) ExpectLongOutputContains(s string) *Run {
+	r.t.Helper()
+	if !strings.Contains(r.Result.LongOutput, s) {
+		r.t.Errorf("Expecting long output containing %q, got %q", s, r.Result.LongOutput)
+	}
+	return r
+}
+
+// PerfData returns the performance data with the label or nil
+func (r *Run) PerfData(label string) *perfdata.PerformanceData {
+	for i := range r.Result.PerfData {
+		if r.Result.PerfData[i].Label == label {
+			return &r.Result.PerfData[i]
+		}
+	}
+	return nil
+}
+
+// ExpectPerfData checks value and unit of measurement of the performance data, NaN expects U
+func (r *Run) ExpectPerfData(label string, value float64, UOM string) *Run {
+	r.t.Helper()
+	pd := r.PerfData(label)
+	switch {
+	case pd == nil:
+		r.t.Errorf("Expecting performance data %s", label)
+	case math.IsNaN(value) && !math.IsNaN(pd.Value):
+		r.t.Errorf("Expecting unknown value of %s, got %s", label, pd.String())
+	case !math.IsNaN(value) && (pd.Value != value || pd.UOM != UOM):
+		r.t.Errorf("Expecting %s=%s%s, got %s", label, perfdata.Format{}.FormatNumber(value), UOM, pd.String())
+	}
+	return r
+}
+
+// ExpectThresholds checks warning and critical threshold of the performance data
+func (r *Run) ExpectThresholds(label string, warning string, critical string) *Run {
+	r.t.Helper()
+	pd := r.PerfData(label)
+	if pd == nil {
+		r.t.Errorf("Expecting performance data %s", label)
+	} else if pd.Warning != warning || pd.Critical != critical {
+		r.t.Errorf("Expecting thresholds %q and %q of %s, got %s", warning, critical, label, pd.String())
+	}
+	return r
+}
+
+// ExpectNoPerfData checks that there is no performance data with the label
+func (r *Run) ExpectNoPerfData(label string) *Run {
+	r.t.Helper()
+	if pd := r.PerfData(label); pd != nil {
+		r.t.Errorf("Expecting no performance data %s, got %s", label, pd.String())
+	}
+	return r
+}
+
+// ExpectGolden compares the output with the file testdata/<name>.golden. The file is
+// written instead if the environment variable UPDATE_GOLDEN is 1.
+func (r *Run) ExpectGolden(name string) *Run {
+	r.t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+
+	if os.Getenv(UpdateGoldenEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatalf("Cannot update golden file: %s", err.Error())
+		}
+		if err := os.WriteFile(path, []byte(r.Output), 0o644); err != nil {
+			r.t.Fatalf("Cannot update golden file: %s", err.Error())
+		}
+		return r
+	}
+
+	golden, err := os.ReadFile(path)
+	if err != nil {
+		r.t.Fatalf("Cannot read golden file, run with %s=1 to create it: %s", UpdateGoldenEnv, err.Error())
+	}
+	if string(golden) != r.Output {
+		r.t.Errorf("Output differs from %s:\n--- expected\n%s--- got\n%s", path, golden, r.Output)
+	}
+	return r
+}
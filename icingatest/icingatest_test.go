@@ -0,0 +1,135 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icingatest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// recorder records the failures instead of failing the test
+type recorder struct {
+	testing.TB
+	failures []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.failures = append(r.failures, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+}
+
+func (r *recorder) expect(t *testing.T, failures ...string) {
+	t.Helper()
+	if strings.Join(r.failures, "\n") != strings.Join(failures, "\n") {
+		t.Errorf("Unexpected failures:\n%s", strings.Join(r.failures, "\n"))
+	}
+	r.failures = nil
+}
+
+func checkDisk(ctx context.Context) icinga.Result {
+	pd := perfdata.CreatePerformanceData("/", 85, "%")
+	pd.SetWarning("80")
+	pd.SetCritical("90")
+	return icinga.Result{
+		Code:       icinga.ExitWarning,
+		Message:    "disk / is 85% full",
+		LongOutput: "/ 85%\n/var 10%",
+		PerfData:   []perfdata.PerformanceData{*pd, *perfdata.CreateUnknownPerformanceData("inodes", "")},
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	RunCheck(t, checkDisk).
+		ExpectCode(icinga.ExitWarning).
+		ExpectSummary("disk / is 85% full").
+		ExpectSummaryContains("85%").
+		ExpectLongOutput("/ 85%\n/var 10%\n").
+		ExpectLongOutputContains("/var").
+		ExpectPerfData("/", 85, "%").
+		ExpectPerfData("inodes", math.NaN(), "").
+		ExpectThresholds("/", "80", "90").
+		ExpectNoPerfData("/home").
+		ExpectGolden("check_disk")
+}
+
+func TestFailures(t *testing.T) {
+	rec := &recorder{TB: t}
+	run := RunCheck(rec, checkDisk)
+
+	run.ExpectCode(icinga.ExitOk)
+	rec.expect(t, "Expecting exit code OK, got WARNING: disk / is 85% full")
+	run.ExpectSummary("fine").ExpectSummaryContains("95%")
+	rec.expect(t, `Expecting summary "fine", got "disk / is 85% full"`, `Expecting summary containing "95%", got "disk / is 85% full"`)
+	run.ExpectLongOutput("/ 85%").ExpectLongOutputContains("/home")
+	rec.expect(t, `Expecting long output "/ 85%", got "/ 85%\n/var 10%"`,
+		`Expecting long output containing "/home", got "/ 85%\n/var 10%"`)
+	run.ExpectPerfData("/", 80, "%").ExpectPerfData("/", math.NaN(), "").ExpectPerfData("inodes", 1, "").ExpectPerfData("/home", 1, "")
+	rec.expect(t, "Expecting /=80%, got '/'=85%;80;90;0;100", "Expecting unknown value of /, got '/'=85%;80;90;0;100",
+		"Expecting inodes=1, got 'inodes'=U;;;;", "Expecting performance data /home")
+	run.ExpectThresholds("/", "70", "90").ExpectThresholds("/home", "", "").ExpectNoPerfData("/")
+	rec.expect(t, `Expecting thresholds "70" and "90" of /, got '/'=85%;80;90;0;100`, "Expecting performance data /home",
+		"Expecting no performance data /, got '/'=85%;80;90;0;100")
+
+	run.Output = "OK - different\n"
+	run.ExpectGolden("check_disk")
+	rec.expect(t, "Output differs from testdata/check_disk.golden:\n--- expected\n"+
+		"WARNING - disk / is 85% full | '/'=85%;80;90;0;100 'inodes'=U;;;;\n/ 85%\n/var 10%\n--- got\nOK - different\n")
+}
+
+func TestCapture(t *testing.T) {
+	Capture(t, func() icinga.ExitCode {
+		return icinga.PrintCriticalWithPerformanceData("down", perfdata.CreatePerformanceData("time", 5, "s"))
+	}).ExpectCode(icinga.ExitCritical).ExpectSummary("down").ExpectPerfData("time", 5, "s")
+
+	run := Capture(t, func() icinga.ExitCode {
+		fmt.Println("OK - raw")
+		return icinga.ExitOk
+	})
+	if run.Output != "OK - raw\n" {
+		t.Errorf("Unexpected output %q", run.Output)
+	}
+}
+
+func TestUpdateGolden(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	defer os.Chdir(wd)
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	RunCheck(t, checkDisk).ExpectGolden("updated")
+
+	data, err := os.ReadFile(filepath.Join(dir, "testdata", "updated.golden"))
+	if err != nil || !strings.HasPrefix(string(data), "WARNING - disk / is 85% full") {
+		t.Errorf("Unexpected golden file %q %v", data, err)
+	}
+}
@@ -21,17 +21,62 @@ import (
 	"fmt"
 )
 
+// Logical operator combining two threshold ranges
+type RangeOperator int
+
+const (
+	OperatorNone RangeOperator = iota
+	OperatorAnd
+	OperatorOr
+)
+
+func (op RangeOperator) String() string {
+	switch op {
+	case OperatorAnd:
+		return "&"
+	case OperatorOr:
+		return "|"
+	default:
+		return ""
+	}
+}
+
+// ThresholdRange is either a single range or, if Operator is set, a compound
+// threshold combining the alerts of the Left and Right ranges
 type ThresholdRange struct {
 	Definition string
 	Metric     string
 	Inside     bool
 	Start      float64
 	End        float64
+	Operator   RangeOperator
+	Left       *ThresholdRange
+	Right      *ThresholdRange
+}
+
+// IsCompound returns true if the range combines other ranges
+func (tr *ThresholdRange) IsCompound() bool {
+	return tr.Operator != OperatorNone
 }
 
 // To print a struct can be represented as JSON
 // Please note that float turned into string to handle the Inf values
 func (tr *ThresholdRange) MarshalJSON() ([]byte, error) {
+	if tr.IsCompound() {
+		return json.Marshal(&struct {
+			Definition string          `json:"definition"`
+			Metric     string          `json:"metric"`
+			Operator   string          `json:"operator"`
+			Left       *ThresholdRange `json:"left"`
+			Right      *ThresholdRange `json:"right"`
+		}{
+			Definition: tr.Definition,
+			Metric:     tr.Metric,
+			Operator:   tr.Operator.String(),
+			Left:       tr.Left,
+			Right:      tr.Right,
+		})
+	}
 	s := fmt.Sprintf("%f", tr.Start)
 	e := fmt.Sprintf("%f", tr.End)
 	return json.Marshal(&struct {
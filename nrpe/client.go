@@ -0,0 +1,171 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nrpe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+// Client queries an NRPE agent like check_nrpe
+type Client struct {
+	// Address of the agent, e.g. "host:5666"
+	Address string
+	// Timeout limits the whole query including connecting, 0 means no timeout
+	Timeout time.Duration
+	// TLSConfig enables TLS if set
+	TLSConfig *tls.Config
+	// Version of the query packets, 0 tries version 4 first and falls back to version 3 and
+	// version 2 for agents closing the connection like NRPE before 4.0 and 3.0
+	Version int16
+}
+
+// CreateClient creates and returns a new Client for the address with a timeout of 10 seconds
+func CreateClient(address string) *Client {
+	c := new(Client)
+	c.Address = address
+	c.Timeout = 10 * time.Second
+
+	return c
+}
+
+// Check runs the command with the arguments on the agent and returns the parsed result. A
+// result with invalid performance data is returned along with the error of the parser,
+// exit codes other than 0 to 3 are UNKNOWN.
+func (c *Client) Check(ctx context.Context, command string, args ...string) (icinga.Result, error) {
+	for _, arg := range args {
+		if strings.Contains(arg, "!") {
+			return icinga.Result{}, errors.New("argument contains '!'")
+		}
+	}
+	query := strings.Join(append([]string{command}, args...), "!")
+
+	p, err := c.Query(ctx, query)
+	if err != nil {
+		return icinga.Result{}, err
+	}
+	return icinga.ParseResult(icinga.ExitCodeOf(int(p.ResultCode)), p.Buffer)
+}
+
+// ServerVersion returns the version string of the agent
+func (c *Client) ServerVersion(ctx context.Context) (string, error) {
+	p, err := c.Query(ctx, VersionCommand)
+	if err != nil {
+		return "", err
+	}
+	return p.Buffer, nil
+}
+
+// Query sends the query buffer "command!arg1!arg2" and returns the response packet
+func (c *Client) Query(ctx context.Context, query string) (*Packet, error) {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	if c.Version != 0 {
+		return c.query(ctx, c.Version, query)
+	}
+	// like check_nrpe, NRPE 3 closes the connection on version 4 and NRPE 2 on version 3
+	var p *Packet
+	var err error
+	for _, version := range []int16{Version4, Version3, Version2} {
+		p, err = c.query(ctx, version, query)
+		if err == nil || ctx.Err() != nil || !isConnectionClosed(err) {
+			break
+		}
+	}
+	return p, err
+}
+
+func (c *Client) query(ctx context.Context, version int16, query string) (*Packet, error) {
+	if len(query) > MaxMessageLength(version) {
+		return nil, fmt.Errorf("query exceeds %d bytes", MaxMessageLength(version))
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// the connection is closed when the context is cancelled
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if c.TLSConfig != nil {
+		config := c.TLSConfig
+		if config.ServerName == "" && !config.InsecureSkipVerify {
+			config = config.Clone()
+			config.ServerName, _, _ = net.SplitHostPort(c.Address)
+		}
+		tlsConn := tls.Client(conn, config)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	if err := WritePacket(conn, &Packet{Version: version, Type: QueryPacket, Buffer: query}); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	p, err := ReadPacket(conn)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	if p.Type != ResponsePacket {
+		return nil, fmt.Errorf("unexpected packet type %d", p.Type)
+	}
+	return p, nil
+}
+
+// contextError returns a timeout error instead of the error of a connection closed by the context
+func contextError(ctx context.Context, err error) error {
+	var netErr net.Error
+	if ctx.Err() == context.DeadlineExceeded || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return errors.New("query timed out")
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func isConnectionClosed(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,152 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nrpe
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+func TestClientCheck(t *testing.T) {
+	s, addr := testServer(t, nil)
+	s.AllowArguments = true
+	c := CreateClient(addr)
+
+	r, err := c.Check(context.Background(), "check_load", "1", "2")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if r.Code != icinga.ExitOk || r.Message != "load 1,2" || r.LongOutput != "details" ||
+		len(r.PerfData) != 1 || r.PerfData[0].Label != "load1" || r.PerfData[0].Value != 0.5 {
+		t.Errorf("Unexpected result: %q", r)
+	}
+
+	r, err = c.Check(context.Background(), "check_missing")
+	if err != nil || r.Code != icinga.ExitUnknown || r.Message != "NRPE: Command 'check_missing' not defined" {
+		t.Errorf("Unexpected result: %q %v", r, err)
+	}
+
+	_, err = c.Check(context.Background(), "check_load", "a!b")
+	expectError(t, err, "argument contains '!'")
+
+	_, err = c.Check(context.Background(), "check_load", strings.Repeat("x", icinga.MaxLengthNRPEv3))
+	expectError(t, err, "query exceeds 65535 bytes")
+
+	version, err := c.ServerVersion(context.Background())
+	if err != nil || version != ServerVersion {
+		t.Errorf("Unexpected version: %q %v", version, err)
+	}
+}
+
+func TestClientLargeOutput(t *testing.T) {
+	_, addr := testServer(t, nil)
+	c := CreateClient(addr)
+
+	r, err := c.Check(context.Background(), "check_big")
+	if err != nil || r.Code != icinga.ExitWarning || len(r.LongOutput) != 5000-1 {
+		t.Errorf("Expecting complete long output, got %d bytes %v", len(r.LongOutput), err)
+	}
+
+	c.Version = Version2
+	r, err = c.Check(context.Background(), "check_big")
+	if err != nil || !strings.HasSuffix(r.LongOutput, icinga.DefaultTruncationMarker) {
+		t.Errorf("Expecting truncated long output, got %d bytes %v", len(r.LongOutput), err)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	_, addr := testServer(t, nil)
+	c := CreateClient(addr)
+	c.Timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := c.Check(context.Background(), "check_slow")
+	expectError(t, err, "query timed out")
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Query exceeded the timeout: %s", time.Since(start))
+	}
+
+	c.Timeout = 0
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	_, err = c.Check(ctx, "check_slow")
+	expectError(t, err, "context canceled")
+}
+
+func TestClientTLS(t *testing.T) {
+	cert, pool := testCertificate(t)
+	_, addr := testServer(t, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+	c := CreateClient(addr)
+
+	c.TLSConfig = &tls.Config{RootCAs: pool}
+	r, err := c.Check(context.Background(), "check_load")
+	if err != nil || r.Code != icinga.ExitOk {
+		t.Errorf("Unexpected result: %q %v", r, err)
+	}
+
+	c.TLSConfig = &tls.Config{}
+	if _, err = c.Check(context.Background(), "check_load"); err == nil {
+		t.Errorf("Expecting certificate error")
+	}
+}
+
+// TestClientVersionFallback queries an agent closing connections of version 3 and 4 queries
+// startVersionAgent starts an agent answering packets of the version and closing the
+// connection on other versions
+func startVersionAgent(t *testing.T, version int16) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if p, err := ReadPacket(conn); err == nil && p.Version == version {
+				WritePacket(conn, &Packet{Version: version, Type: ResponsePacket, ResultCode: 2,
+					Buffer: fmt.Sprintf("version %d agent", version)})
+			}
+			conn.Close()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestClientVersionFallback(t *testing.T) {
+	for _, version := range []int16{Version3, Version2} {
+		c := CreateClient(startVersionAgent(t, version))
+		r, err := c.Check(context.Background(), "check_any")
+		if err != nil || r.Code != icinga.ExitCritical || r.Message != fmt.Sprintf("version %d agent", version) {
+			t.Errorf("Unexpected result of version %d agent: %q %v", version, r, err)
+		}
+
+		c.Version = Version4
+		if _, err = c.Check(context.Background(), "check_any"); err == nil {
+			t.Errorf("Expecting error without fallback")
+		}
+	}
+}
@@ -0,0 +1,196 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nrpe
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+/*
+ * NRPE packets, all integers are in network byte order:
+ *
+ *	version 2: version(2) type(2) crc32(4) result(2) buffer(1024) padding(2)
+ *	version 3: version(2) type(2) crc32(4) result(2) alignment(2) length(4) buffer(length) padding(3)
+ *	version 4: version(2) type(2) crc32(4) result(2) alignment(2) length(4) buffer(length)
+ *
+ * The buffer is a null terminated string followed by random bytes, the crc32 is
+ * calculated over the whole packet with the crc32 field set to 0.
+ */
+
+// Packet versions
+const (
+	Version2 int16 = 2
+	Version3 int16 = 3
+	Version4 int16 = 4
+)
+
+// Packet types
+const (
+	QueryPacket    int16 = 1
+	ResponsePacket int16 = 2
+)
+
+const (
+	// BufferLength is the buffer length of version 2 and the minimum of later versions
+	BufferLength = 1024
+	// MaxBufferLength limits the buffer length of version 3 and 4, check_nrpe rejects longer buffers
+	MaxBufferLength = icinga.MaxLengthNRPEv3 + 1
+
+	headerLength   = 16
+	v2PacketLength = 10 + BufferLength + 2
+	v3Padding      = 3
+)
+
+// Packet of the NRPE protocol
+type Packet struct {
+	Version    int16
+	Type       int16
+	ResultCode int16
+	Buffer     string
+}
+
+// MaxMessageLength returns the maximum length of the buffer string of the packet version
+func MaxMessageLength(version int16) int {
+	if version == Version2 {
+		return icinga.MaxLengthNRPEv2
+	}
+	return icinga.MaxLengthNRPEv3
+}
+
+// MarshalBinary encodes the packet including padding and crc32
+func (p *Packet) MarshalBinary() ([]byte, error) {
+	if p.Version < Version2 || p.Version > Version4 {
+		return nil, fmt.Errorf("unsupported packet version %d", p.Version)
+	}
+	if len(p.Buffer) > MaxMessageLength(p.Version) {
+		return nil, fmt.Errorf("buffer exceeds %d bytes", MaxMessageLength(p.Version))
+	}
+	if bytes.IndexByte([]byte(p.Buffer), 0) >= 0 {
+		return nil, errors.New("buffer contains a null byte")
+	}
+
+	bufferLength := BufferLength
+	if p.Version != Version2 && len(p.Buffer)+1 > bufferLength {
+		bufferLength = len(p.Buffer) + 1
+	}
+
+	// the buffer is filled with random bytes after the terminating null byte
+	buffer := make([]byte, bufferLength)
+	if _, err := rand.Read(buffer); err != nil {
+		return nil, err
+	}
+	copy(buffer, p.Buffer)
+	buffer[len(p.Buffer)] = 0
+
+	var data []byte
+	if p.Version == Version2 {
+		data = make([]byte, v2PacketLength)
+		copy(data[10:], buffer)
+	} else {
+		length := headerLength + bufferLength
+		if p.Version == Version3 {
+			length += v3Padding
+		}
+		data = make([]byte, length)
+		binary.BigEndian.PutUint32(data[12:], uint32(bufferLength))
+		copy(data[headerLength:], buffer)
+	}
+	binary.BigEndian.PutUint16(data[0:], uint16(p.Version))
+	binary.BigEndian.PutUint16(data[2:], uint16(p.Type))
+	binary.BigEndian.PutUint16(data[8:], uint16(p.ResultCode))
+	binary.BigEndian.PutUint32(data[4:], crc32.ChecksumIEEE(data))
+
+	return data, nil
+}
+
+// WritePacket writes the encoded packet
+func WritePacket(w io.Writer, p *Packet) error {
+	data, err := p.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// ReadPacket reads and verifies a packet of any supported version
+func ReadPacket(r io.Reader) (*Packet, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	p := new(Packet)
+	p.Version = int16(binary.BigEndian.Uint16(header[0:]))
+	p.Type = int16(binary.BigEndian.Uint16(header[2:]))
+
+	var data []byte
+	switch p.Version {
+	case Version2:
+		data = make([]byte, v2PacketLength)
+		copy(data, header)
+		if _, err := io.ReadFull(r, data[4:]); err != nil {
+			return nil, err
+		}
+	case Version3, Version4:
+		data = make([]byte, headerLength)
+		copy(data, header)
+		if _, err := io.ReadFull(r, data[4:]); err != nil {
+			return nil, err
+		}
+		bufferLength := binary.BigEndian.Uint32(data[12:])
+		if bufferLength == 0 || bufferLength > MaxBufferLength {
+			return nil, fmt.Errorf("invalid buffer length %d", bufferLength)
+		}
+		length := headerLength + int(bufferLength)
+		if p.Version == Version3 {
+			length += v3Padding
+		}
+		data = append(data, make([]byte, length-headerLength)...)
+		if _, err := io.ReadFull(r, data[headerLength:]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported packet version %d", p.Version)
+	}
+
+	crc := binary.BigEndian.Uint32(data[4:])
+	binary.BigEndian.PutUint32(data[4:], 0)
+	if crc32.ChecksumIEEE(data) != crc {
+		return nil, errors.New("crc32 mismatch")
+	}
+
+	p.ResultCode = int16(binary.BigEndian.Uint16(data[8:]))
+	buffer := data[10 : 10+BufferLength]
+	if p.Version != Version2 {
+		buffer = data[headerLength : headerLength+int(binary.BigEndian.Uint32(data[12:]))]
+	}
+	if i := bytes.IndexByte(buffer, 0); i >= 0 {
+		buffer = buffer[:i]
+	}
+	p.Buffer = string(buffer)
+
+	return p, nil
+}
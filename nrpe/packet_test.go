@@ -0,0 +1,133 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nrpe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+func TestPacketLength(t *testing.T) {
+	packetLength(t, Version2, "check_load", 1036)
+	packetLength(t, Version3, "check_load", 16+1024+3)
+	packetLength(t, Version4, "check_load", 16+1024)
+	packetLength(t, Version4, strings.Repeat("x", 2000), 16+2001)
+	packetLength(t, Version3, strings.Repeat("x", 2000), 16+2001+3)
+}
+
+func packetLength(t *testing.T, version int16, buffer string, want int) {
+	t.Helper()
+	data, err := (&Packet{Version: version, Type: QueryPacket, Buffer: buffer}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if len(data) != want {
+		t.Errorf("Packet of version %d has length %d, want %d", version, len(data), want)
+	}
+}
+
+func TestPacketLayout(t *testing.T) {
+	data, _ := (&Packet{Version: Version2, Type: ResponsePacket, ResultCode: 2, Buffer: "CRITICAL"}).MarshalBinary()
+
+	if binary.BigEndian.Uint16(data[0:]) != 2 || binary.BigEndian.Uint16(data[2:]) != 2 || binary.BigEndian.Uint16(data[8:]) != 2 {
+		t.Errorf("Unexpected header % x", data[:10])
+	}
+	if string(data[10:19]) != "CRITICAL\x00" {
+		t.Errorf("Unexpected buffer %q", data[10:19])
+	}
+
+	crc := binary.BigEndian.Uint32(data[4:])
+	binary.BigEndian.PutUint32(data[4:], 0)
+	if crc32.ChecksumIEEE(data) != crc {
+		t.Errorf("Unexpected crc32 %x", crc)
+	}
+
+	data, _ = (&Packet{Version: Version4, Type: QueryPacket, Buffer: "check_load"}).MarshalBinary()
+	if binary.BigEndian.Uint32(data[12:]) != 1024 || string(data[16:27]) != "check_load\x00" {
+		t.Errorf("Unexpected version 4 packet % x", data[:27])
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	for _, version := range []int16{Version2, Version3, Version4} {
+		for _, buffer := range []string{"", "check_load!1!2", strings.Repeat("y", MaxMessageLength(version))} {
+			if version != Version2 && len(buffer) > 5000 {
+				buffer = buffer[:5000]
+			}
+			p := Packet{Version: version, Type: ResponsePacket, ResultCode: 3, Buffer: buffer}
+			var buf bytes.Buffer
+			if err := WritePacket(&buf, &p); err != nil {
+				t.Fatalf("Unexpected error occured: %s", err.Error())
+			}
+			read, err := ReadPacket(&buf)
+			if err != nil {
+				t.Fatalf("Unexpected error for version %d: %s", version, err.Error())
+			}
+			if *read != p {
+				t.Errorf("Packet of version %d changed: %+v", version, read)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("Packet of version %d not read completely, %d bytes left", version, buf.Len())
+			}
+		}
+	}
+}
+
+func TestPacketError(t *testing.T) {
+	_, err := (&Packet{Version: 1}).MarshalBinary()
+	expectError(t, err, "unsupported packet version 1")
+
+	_, err = (&Packet{Version: Version2, Buffer: strings.Repeat("x", 1024)}).MarshalBinary()
+	expectError(t, err, "buffer exceeds 1023 bytes")
+
+	_, err = (&Packet{Version: Version4, Buffer: "a\x00b"}).MarshalBinary()
+	expectError(t, err, "buffer contains a null byte")
+
+	data, _ := (&Packet{Version: Version4, Type: QueryPacket, Buffer: "check_load"}).MarshalBinary()
+	data[17] = 'X'
+	_, err = ReadPacket(bytes.NewReader(data))
+	expectError(t, err, "crc32 mismatch")
+
+	binary.BigEndian.PutUint32(data[12:], MaxBufferLength+1)
+	_, err = ReadPacket(bytes.NewReader(data))
+	expectError(t, err, "invalid buffer length 65537")
+
+	binary.BigEndian.PutUint16(data[0:], 5)
+	_, err = ReadPacket(bytes.NewReader(data))
+	expectError(t, err, "unsupported packet version 5")
+
+	_, err = ReadPacket(bytes.NewReader(data[:100]))
+	expectError(t, err, "unsupported packet version 5")
+
+	data, _ = (&Packet{Version: Version2, Type: QueryPacket, Buffer: "check_load"}).MarshalBinary()
+	_, err = ReadPacket(bytes.NewReader(data[:100]))
+	expectError(t, err, "unexpected EOF")
+}
+
+func expectError(t *testing.T, err error, message string) {
+	t.Helper()
+	if err == nil {
+		t.Errorf("Expecting error %s", message)
+		return
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Errorf("Expecting error: %s, got = %s", message, err.Error())
+	}
+}
@@ -0,0 +1,185 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nrpe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+// VersionCommand is the command check_nrpe sends without command to query the version
+const VersionCommand = "_NRPE_CHECK"
+
+// ServerVersion is returned for the VersionCommand
+const ServerVersion = "NRPE v4.1.0 (icinga_plugins)"
+
+// CommandFunc runs a command with the arguments passed by check_nrpe -a
+type CommandFunc func(ctx context.Context, args []string) icinga.Result
+
+// Server answers NRPE queries by running the commands registered by name.
+// TLS uses certificates, the anonymous Diffie-Hellman mode of the original NRPE
+// daemon is not supported by crypto/tls and needs check_nrpe with certificates.
+type Server struct {
+	// Timeout limits the run time of a command and the reading and writing of packets
+	Timeout time.Duration
+	// TLSConfig enables TLS if set
+	TLSConfig *tls.Config
+	// AllowArguments allows commands with arguments like dont_blame_nrpe of NRPE
+	AllowArguments bool
+
+	mu        sync.Mutex
+	commands  map[string]CommandFunc
+	listeners map[net.Listener]bool
+	closed    bool
+}
+
+// CreateServer creates and returns a new Server with a command timeout of 60 seconds
+func CreateServer() *Server {
+	s := new(Server)
+	s.Timeout = 60 * time.Second
+	s.commands = make(map[string]CommandFunc)
+	s.listeners = make(map[net.Listener]bool)
+
+	return s
+}
+
+// Handle registers the command function under the name
+func (s *Server) Handle(name string, command CommandFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands[name] = command
+}
+
+// ListenAndServe listens on the TCP address and serves the queries
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts connections on the listener until the server is closed
+func (s *Server) Serve(l net.Listener) error {
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		l.Close()
+		return errors.New("server closed")
+	}
+	s.listeners[l] = true
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.serveConn(conn)
+	}
+}
+
+// Close stops all listeners of the server
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	return nil
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	if s.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(2 * s.Timeout))
+	}
+
+	query, err := ReadPacket(conn)
+	if err != nil || query.Type != QueryPacket {
+		return
+	}
+
+	response := &Packet{Version: query.Version, Type: ResponsePacket, ResultCode: int16(icinga.ExitOk)}
+	if query.Buffer == VersionCommand {
+		response.Buffer = ServerVersion
+	} else {
+		result := s.run(query.Buffer)
+
+		printer := icinga.CreatePrinter(nil)
+		printer.MaxLength = MaxMessageLength(query.Version)
+		output := printer.Render(result.Message, result.LongOutput, result.Code, result.PerfData)
+
+		// a summary line exceeding the packet is cut as last resort
+		output = strings.TrimSuffix(output, "\n")
+		if len(output) > printer.MaxLength {
+			// without splitting a multi-byte character
+			cut := printer.MaxLength
+			for cut > 0 && !utf8.RuneStart(output[cut]) {
+				cut--
+			}
+			output = output[:cut]
+		}
+
+		response.ResultCode = int16(result.Code)
+		response.Buffer = output
+	}
+
+	WritePacket(conn, response)
+}
+
+// run runs the command of the query "command!arg1!arg2"
+func (s *Server) run(query string) icinga.Result {
+	parts := strings.Split(query, "!")
+	name, args := parts[0], parts[1:]
+
+	s.mu.Lock()
+	command, ok := s.commands[name]
+	s.mu.Unlock()
+	if !ok {
+		return icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("NRPE: Command '%s' not defined", name)}
+	}
+	if len(args) > 0 && !s.AllowArguments {
+		return icinga.Result{Code: icinga.ExitUnknown, Message: "NRPE: Command arguments are not allowed"}
+	}
+
+	return icinga.RunCheck(context.Background(), func(ctx context.Context) icinga.Result {
+		return command(ctx, args)
+	}, s.Timeout)
+}
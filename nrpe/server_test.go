@@ -0,0 +1,215 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nrpe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// testCertificate returns a self-signed certificate for 127.0.0.1 and a pool trusting it
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "nrpe test"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func testServer(t *testing.T, tlsConfig *tls.Config) (*Server, string) {
+	s := CreateServer()
+	s.TLSConfig = tlsConfig
+	s.Timeout = time.Second
+	s.Handle("check_load", func(ctx context.Context, args []string) icinga.Result {
+		pd := perfdata.CreatePerformanceData("load1", 0.5, "")
+		return icinga.Result{Code: icinga.ExitOk, Message: "load " + strings.Join(args, ","), LongOutput: "details",
+			PerfData: []perfdata.PerformanceData{*pd}}
+	})
+	s.Handle("check_big", func(ctx context.Context, args []string) icinga.Result {
+		return icinga.Result{Code: icinga.ExitWarning, Message: "big", LongOutput: strings.Repeat("line\n", 1000)}
+	})
+	s.Handle("check_slow", func(ctx context.Context, args []string) icinga.Result {
+		<-ctx.Done()
+		return icinga.Result{Code: icinga.ExitOk}
+	})
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+
+	return s, l.Addr().String()
+}
+
+// query is a minimal local client sending one query packet
+func query(t *testing.T, addr string, version int16, buffer string, tlsConfig *tls.Config) *Packet {
+	t.Helper()
+	var conn net.Conn
+	var err error
+	if tlsConfig != nil {
+		conn, err = tls.Dial("tcp", addr, tlsConfig)
+	} else {
+		conn, err = net.Dial("tcp", addr)
+	}
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	defer conn.Close()
+
+	if err = WritePacket(conn, &Packet{Version: version, Type: QueryPacket, Buffer: buffer}); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	p, err := ReadPacket(conn)
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if p.Type != ResponsePacket || p.Version != version {
+		t.Errorf("Unexpected response packet type %d version %d", p.Type, p.Version)
+	}
+	return p
+}
+
+func expectResponse(t *testing.T, p *Packet, code icinga.ExitCode, buffer string) {
+	t.Helper()
+	if p.ResultCode != int16(code) || p.Buffer != buffer {
+		t.Errorf("Unexpected response %d %q, want %d %q", p.ResultCode, p.Buffer, code, buffer)
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, addr := testServer(t, nil)
+
+	for _, version := range []int16{Version2, Version3, Version4} {
+		expectResponse(t, query(t, addr, version, "check_load", nil), icinga.ExitOk, "OK - load  | 'load1'=0.5;;;;\ndetails")
+		expectResponse(t, query(t, addr, version, VersionCommand, nil), icinga.ExitOk, ServerVersion)
+		expectResponse(t, query(t, addr, version, "check_missing", nil), icinga.ExitUnknown,
+			"UNKNOWN - NRPE: Command 'check_missing' not defined")
+		expectResponse(t, query(t, addr, version, "check_load!1!2", nil), icinga.ExitUnknown,
+			"UNKNOWN - NRPE: Command arguments are not allowed")
+	}
+
+	s.AllowArguments = true
+	expectResponse(t, query(t, addr, Version4, "check_load!1!2", nil), icinga.ExitOk, "OK - load 1,2 | 'load1'=0.5;;;;\ndetails")
+
+	expectResponse(t, query(t, addr, Version4, "check_slow", nil), icinga.ExitUnknown, "UNKNOWN - check timed out after 1s")
+}
+
+func TestServerLargeOutput(t *testing.T) {
+	_, addr := testServer(t, nil)
+
+	p := query(t, addr, Version2, "check_big", nil)
+	if len(p.Buffer) > 1023 || !strings.HasSuffix(p.Buffer, "\n"+icinga.DefaultTruncationMarker) {
+		t.Errorf("Expecting truncated output of version 2, got %d bytes", len(p.Buffer))
+	}
+
+	p = query(t, addr, Version4, "check_big", nil)
+	if len(p.Buffer) != len("WARNING - big\n")+5000-1 {
+		t.Errorf("Expecting complete output of version 4, got %d bytes", len(p.Buffer))
+	}
+}
+
+func TestServerHugeOutput(t *testing.T) {
+	// the default timeout as rendering must not depend on the speed of the machine
+	s := CreateServer()
+	s.Handle("check_huge", func(ctx context.Context, args []string) icinga.Result {
+		return icinga.Result{Code: icinga.ExitOk, Message: "huge", LongOutput: strings.Repeat("0123456789\n", 10000)}
+	})
+	s.Handle("check_summary", func(ctx context.Context, args []string) icinga.Result {
+		return icinga.Result{Code: icinga.ExitOk, Message: "x" + strings.Repeat("ä", 1000)}
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	addr := l.Addr().String()
+
+	for _, version := range []int16{Version3, Version4} {
+		p := query(t, addr, version, "check_huge", nil)
+		if len(p.Buffer) > icinga.MaxLengthNRPEv3 || !strings.HasSuffix(p.Buffer, "\n"+icinga.DefaultTruncationMarker) {
+			t.Errorf("Expecting output truncated to %d bytes, got %d bytes", icinga.MaxLengthNRPEv3, len(p.Buffer))
+		}
+	}
+
+	// a summary exceeding the packet is cut at a character boundary
+	p := query(t, addr, Version2, "check_summary", nil)
+	if len(p.Buffer) != icinga.MaxLengthNRPEv2-1 || !utf8.ValidString(p.Buffer) {
+		t.Errorf("Expecting valid UTF-8 cut to %d bytes, got %d bytes", icinga.MaxLengthNRPEv2-1, len(p.Buffer))
+	}
+}
+
+func TestServerTLS(t *testing.T) {
+	cert, pool := testCertificate(t)
+	_, addr := testServer(t, &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12})
+
+	p := query(t, addr, Version4, "check_load", &tls.Config{RootCAs: pool})
+	expectResponse(t, p, icinga.ExitOk, "OK - load  | 'load1'=0.5;;;;\ndetails")
+}
+
+func TestServerClosed(t *testing.T) {
+	s := CreateServer()
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+
+	done := make(chan error)
+	go func() { done <- s.Serve(l) }()
+	time.Sleep(10 * time.Millisecond)
+	s.Close()
+
+	if err := <-done; err != nil {
+		t.Errorf("Unexpected error occured: %s", err.Error())
+	}
+	if err := s.Serve(l); err == nil || err.Error() != "server closed" {
+		t.Errorf("Expecting server closed error, got %v", err)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nsca
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+/*
+ * NSCA-ng protocol, each line is terminated by "\r\n":
+ *
+ *	client: MOIN 1 <session>	server: MOIN 1
+ *	client: PUSH <length>		server: OKAY
+ *	client: <commands>		server: OKAY
+ *	client: QUIT			server: OKAY
+ *
+ * The server answers with "FAIL <reason>" or "BAIL <reason>" on errors.
+ */
+
+// NGOutputLength is the default output length of NGClient
+const NGOutputLength = 64 * 1024
+
+// DialFunc opens a connection to an NSCA-ng server
+type DialFunc func(ctx context.Context) (net.Conn, error)
+
+// NGClient sends passive check results to an NSCA-ng server like send_nsca of NSCA-ng.
+// Dial opens the connection, DialPSK provides the TLS-PSK connection NSCA-ng requires.
+type NGClient struct {
+	Dial DialFunc
+	// Timeout limits each connection, 0 means no timeout
+	Timeout time.Duration
+	// OutputLength limits the output of each result, 0 means NGOutputLength
+	OutputLength int
+	// BatchSize is the maximum number of results sent on one connection
+	BatchSize int
+	// Retries of a failed batch after RetryDelay
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// CreateNGClient creates and returns a new NGClient with a timeout of 10 seconds, an
+// output length of 64 KiB, batches of 100 results and 2 retries
+func CreateNGClient(dial DialFunc) *NGClient {
+	c := new(NGClient)
+	c.Dial = dial
+	c.Timeout = 10 * time.Second
+	c.OutputLength = NGOutputLength
+	c.BatchSize = 100
+	c.Retries = 2
+	c.RetryDelay = time.Second
+
+	return c
+}
+
+// Send sends the results in batches, each batch is acknowledged by the server
+func (c *NGClient) Send(ctx context.Context, results ...PassiveResult) error {
+	for _, r := range results {
+		if r.Host == "" || strings.ContainsAny(r.Host+r.Service, ";\n") {
+			return fmt.Errorf("invalid host or service name %q %q", r.Host, r.Service)
+		}
+	}
+	return sendBatches(ctx, results, c.BatchSize, c.Retries, c.RetryDelay, c.send)
+}
+
+// outputLength returns the output length of the results
+func (c *NGClient) outputLength() int {
+	if c.OutputLength <= 0 {
+		return NGOutputLength
+	}
+	return c.OutputLength
+}
+
+// command returns the external command of the result
+func (c *NGClient) command(r PassiveResult) string {
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	output := formatOutput(r.Result, c.outputLength())
+	if r.Service == "" {
+		return fmt.Sprintf("[%d] PROCESS_HOST_CHECK_RESULT;%s;%d;%s\n", t.Unix(), r.Host, int(r.Result.Code), output)
+	}
+	return fmt.Sprintf("[%d] PROCESS_SERVICE_CHECK_RESULT;%s;%s;%d;%s\n", t.Unix(), r.Host, r.Service,
+		int(r.Result.Code), output)
+}
+
+func (c *NGClient) send(ctx context.Context, results []PassiveResult) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	conn, err := c.Dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	session := make([]byte, 4)
+	rand.Read(session)
+
+	var commands strings.Builder
+	for _, r := range results {
+		commands.WriteString(c.command(r))
+	}
+
+	reader := bufio.NewReader(conn)
+	for _, step := range []struct{ request, response string }{
+		{"MOIN 1 " + hex.EncodeToString(session) + "\r\n", "MOIN 1"},
+		{fmt.Sprintf("PUSH %d\r\n", commands.Len()), "OKAY"},
+		{commands.String(), "OKAY"},
+		{"QUIT\r\n", "OKAY"},
+	} {
+		if _, err := conn.Write([]byte(step.request)); err != nil {
+			return err
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != step.response && !strings.HasPrefix(line, step.response+" ") {
+			return fmt.Errorf("server failed: %s", line)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nsca
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+// fakeNGServer is an NSCA-ng server collecting the commands, with TLS-PSK if a key is
+// set. The first failures pushes are answered with FAIL.
+type fakeNGServer struct {
+	listener net.Listener
+	failures int
+	key      []byte
+
+	mu       sync.Mutex
+	pushes   int
+	commands []string
+}
+
+func startFakeNGServer(t *testing.T, failures int, key []byte) *fakeNGServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	s := &fakeNGServer{listener: l, failures: failures, key: key}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if s.key != nil {
+				c, identity, err := pskServer(conn, s.key, pskSuites[0].id)
+				if err != nil || identity != "web1" {
+					conn.Close()
+					continue
+				}
+				conn = c
+			}
+			s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeNGServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 3 && fields[0] == "MOIN" && fields[1] == "1":
+			io.WriteString(conn, "MOIN 1\r\n")
+		case len(fields) == 2 && fields[0] == "PUSH":
+			length, _ := strconv.Atoi(fields[1])
+			io.WriteString(conn, "OKAY\r\n")
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.pushes++
+			if s.pushes <= s.failures {
+				s.mu.Unlock()
+				io.WriteString(conn, "FAIL Cannot submit commands\r\n")
+				return
+			}
+			s.commands = append(s.commands, strings.SplitAfter(string(data), "\n")...)
+			s.commands = s.commands[:len(s.commands)-1]
+			s.mu.Unlock()
+			io.WriteString(conn, "OKAY\r\n")
+		case len(fields) == 1 && fields[0] == "QUIT":
+			io.WriteString(conn, "OKAY\r\n")
+			return
+		default:
+			io.WriteString(conn, "BAIL Invalid request\r\n")
+			return
+		}
+	}
+}
+
+func (s *fakeNGServer) client() *NGClient {
+	return CreateNGClient(func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "tcp", s.listener.Addr().String())
+	})
+}
+
+func TestNGClientSend(t *testing.T) {
+	s := startFakeNGServer(t, 0, nil)
+	c := s.client()
+	c.BatchSize = 3
+
+	now := time.Unix(1700000000, 0)
+	results := testResults(4)
+	for i := range results {
+		results[i].Time = now
+	}
+	results = append(results, PassiveResult{Host: "db1", Time: now, Result: icinga.Result{Code: icinga.ExitCritical, Message: "down"}})
+
+	if err := c.Send(context.Background(), results...); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pushes != 2 || len(s.commands) != 5 {
+		t.Fatalf("Expecting 5 commands in 2 pushes, got %d in %d", len(s.commands), s.pushes)
+	}
+	want := `[1700000000] PROCESS_SERVICE_CHECK_RESULT;web1;http;1;WARNING - slow | 'time'=2s;;;;\nline 1\nline 2` + "\n"
+	if s.commands[0] != want {
+		t.Errorf("Unexpected command %q", s.commands[0])
+	}
+	if s.commands[4] != "[1700000000] PROCESS_HOST_CHECK_RESULT;db1;2;CRITICAL - down\n" {
+		t.Errorf("Unexpected command %q", s.commands[4])
+	}
+}
+
+func TestNGClientZeroValue(t *testing.T) {
+	s := startFakeNGServer(t, 0, nil)
+	c := &NGClient{Dial: s.client().Dial}
+
+	r := PassiveResult{Host: "db1", Time: time.Unix(1700000000, 0), Result: icinga.Result{Code: icinga.ExitCritical, Message: "down"}}
+	if err := c.Send(context.Background(), r); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.commands) != 1 || s.commands[0] != "[1700000000] PROCESS_HOST_CHECK_RESULT;db1;2;CRITICAL - down\n" {
+		t.Errorf("Unexpected commands %q", s.commands)
+	}
+}
+
+func TestNGClientRetry(t *testing.T) {
+	s := startFakeNGServer(t, 2, nil)
+	c := s.client()
+	c.RetryDelay = time.Millisecond
+
+	if err := c.Send(context.Background(), testResults(1)...); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	s.mu.Lock()
+	s.failures = s.pushes + 3
+	s.mu.Unlock()
+	err := c.Send(context.Background(), testResults(1)...)
+	if err == nil || err.Error() != "server failed: FAIL Cannot submit commands" {
+		t.Errorf("Expecting server error, got %v", err)
+	}
+}
+
+func TestNGClientPSK(t *testing.T) {
+	s := startFakeNGServer(t, 0, []byte("secret"))
+	c := CreateNGClient(DialPSK(s.listener.Addr().String(), "web1", "secret"))
+
+	r := PassiveResult{Host: "db1", Time: time.Unix(1700000000, 0), Result: icinga.Result{Code: icinga.ExitCritical, Message: "down"}}
+	if err := c.Send(context.Background(), r); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.commands) != 1 || s.commands[0] != "[1700000000] PROCESS_HOST_CHECK_RESULT;db1;2;CRITICAL - down\n" {
+		t.Errorf("Unexpected commands %q", s.commands)
+	}
+}
+
+func TestNGClientInvalidResult(t *testing.T) {
+	c := CreateNGClient(func(ctx context.Context) (net.Conn, error) {
+		return nil, fmt.Errorf("not connected")
+	})
+	for _, r := range []PassiveResult{{}, {Host: "h;x"}, {Host: "h", Service: "a\nb"}} {
+		if err := c.Send(context.Background(), r); err == nil || err.Error() == "not connected" {
+			t.Errorf("Expecting invalid name error for %+v, got %v", r, err)
+		}
+	}
+}
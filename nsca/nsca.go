@@ -0,0 +1,309 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nsca
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+/*
+ * NSCA 2 protocol:
+ *
+ *	server: iv(128) timestamp(4)
+ *	client: version(2) padding(2) crc32(4) timestamp(4) result(2) host(64) service(128) output(n) padding
+ *
+ * The client sends one packet per result with the timestamp of the server, n is 512 before
+ * NSCA 2.9 and 4096 since. The crc32 is calculated with the crc32 field set to 0, then the
+ * packet is encrypted.
+ */
+
+// Encryption methods of NSCA, the values are the decryption_method of nsca.cfg
+type Encryption int
+
+const (
+	EncryptionNone Encryption = 0
+	EncryptionXOR  Encryption = 1
+	// EncryptionAES is RIJNDAEL-128 of libmcrypt in 8 bit CFB mode with a 256 bit key
+	EncryptionAES Encryption = 14
+)
+
+// Output lengths of NSCA packets
+const (
+	OutputLengthLegacy = 512
+	OutputLength       = 4096
+)
+
+const (
+	packetVersion = 3
+	ivLength      = 128
+	hostLength    = 64
+	serviceLength = 128
+	headerLength  = 14
+	aesKeyLength  = 32
+)
+
+// PassiveResult is the check result of a host or, if Service is set, a service
+type PassiveResult struct {
+	Host    string
+	Service string
+	// Time of the check, NSCA uses the time of the server instead
+	Time   time.Time
+	Result icinga.Result
+}
+
+// packetLength returns the length of a packet including the trailing alignment
+func packetLength(outputLength int) int {
+	length := headerLength + hostLength + serviceLength + outputLength
+	return (length + 3) &^ 3
+}
+
+// encodePacket returns the unencrypted packet of the result
+func encodePacket(r PassiveResult, timestamp uint32, outputLength int) ([]byte, error) {
+	if r.Host == "" || len(r.Host) >= hostLength {
+		return nil, fmt.Errorf("invalid host name length %d", len(r.Host))
+	}
+	if len(r.Service) >= serviceLength {
+		return nil, fmt.Errorf("service name exceeds %d bytes", serviceLength-1)
+	}
+
+	data := make([]byte, packetLength(outputLength))
+	binary.BigEndian.PutUint16(data[0:], packetVersion)
+	binary.BigEndian.PutUint32(data[8:], timestamp)
+	binary.BigEndian.PutUint16(data[12:], uint16(r.Result.Code))
+	copy(data[headerLength:], r.Host)
+	copy(data[headerLength+hostLength:], r.Service)
+	copy(data[headerLength+hostLength+serviceLength:], formatOutput(r.Result, outputLength-1))
+	binary.BigEndian.PutUint32(data[4:], crc32.ChecksumIEEE(data))
+
+	return data, nil
+}
+
+// formatOutput renders the result on one line with escaped line breaks as understood
+// by external commands, the output is shortened to the limit
+func formatOutput(r icinga.Result, limit int) string {
+	printer := icinga.CreatePrinter(nil)
+	printer.MaxLength = limit
+	for {
+		output := printer.Render(r.Message, r.LongOutput, r.Code, r.PerfData)
+		output = strings.ReplaceAll(strings.TrimSuffix(output, "\n"), "\n", `\n`)
+		if len(output) <= limit {
+			return output
+		}
+		if printer.MaxLength <= 0 || printer.MaxLength-(len(output)-limit) <= 0 {
+			// a summary line exceeding the limit is cut as last resort without splitting
+			// a multi-byte character
+			for limit > 0 && !utf8.RuneStart(output[limit]) {
+				limit--
+			}
+			return output[:limit]
+		}
+		printer.MaxLength -= len(output) - limit
+	}
+}
+
+// crypter encrypts the packets sent on one connection
+type crypter struct {
+	method   Encryption
+	iv       []byte
+	password []byte
+	block    cipher.Block
+	register []byte
+}
+
+func newCrypter(method Encryption, iv []byte, password string) (*crypter, error) {
+	c := &crypter{method: method, iv: iv, password: []byte(password)}
+	switch method {
+	case EncryptionNone, EncryptionXOR:
+	case EncryptionAES:
+		key := make([]byte, aesKeyLength)
+		copy(key, password)
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		c.block = block
+		c.register = append([]byte{}, iv[:aes.BlockSize]...)
+	default:
+		return nil, fmt.Errorf("unsupported encryption method %d", method)
+	}
+	return c, nil
+}
+
+// encrypt encrypts the packet in place, the AES stream continues over all packets of the connection
+func (c *crypter) encrypt(data []byte) {
+	c.crypt(data, true)
+}
+
+func (c *crypter) decrypt(data []byte) {
+	c.crypt(data, false)
+}
+
+func (c *crypter) crypt(data []byte, encrypt bool) {
+	switch c.method {
+	case EncryptionXOR:
+		for i := range data {
+			data[i] ^= c.iv[i%len(c.iv)]
+			if len(c.password) > 0 {
+				data[i] ^= c.password[i%len(c.password)]
+			}
+		}
+	case EncryptionAES:
+		stream := make([]byte, aes.BlockSize)
+		for i := range data {
+			c.block.Encrypt(stream, c.register)
+			cipherByte := data[i]
+			data[i] ^= stream[0]
+			if encrypt {
+				cipherByte = data[i]
+			}
+			copy(c.register, c.register[1:])
+			c.register[len(c.register)-1] = cipherByte
+		}
+	}
+}
+
+// Client sends passive check results to an NSCA server like send_nsca
+type Client struct {
+	// Address of the server, e.g. "icinga:5667"
+	Address    string
+	Encryption Encryption
+	Password   string
+	// OutputLength of the packets, OutputLengthLegacy for servers before NSCA 2.9,
+	// 0 means OutputLength
+	OutputLength int
+	// Timeout limits each connection, 0 means no timeout
+	Timeout time.Duration
+	// BatchSize is the maximum number of results sent on one connection
+	BatchSize int
+	// Retries of a failed batch after RetryDelay
+	Retries    int
+	RetryDelay time.Duration
+}
+
+// CreateClient creates and returns a new Client for the address sending unencrypted packets
+// of NSCA 2.9 with a timeout of 10 seconds, batches of 100 results and 2 retries
+func CreateClient(address string) *Client {
+	c := new(Client)
+	c.Address = address
+	c.OutputLength = OutputLength
+	c.Timeout = 10 * time.Second
+	c.BatchSize = 100
+	c.Retries = 2
+	c.RetryDelay = time.Second
+
+	return c
+}
+
+// Send sends the results in batches. NSCA does not acknowledge results, so a batch
+// failing after sending some results is sent again completely.
+func (c *Client) Send(ctx context.Context, results ...PassiveResult) error {
+	for _, r := range results {
+		if _, err := encodePacket(r, 0, c.outputLength()); err != nil {
+			return err
+		}
+	}
+	return sendBatches(ctx, results, c.BatchSize, c.Retries, c.RetryDelay, c.send)
+}
+
+// outputLength returns the output length of the packets
+func (c *Client) outputLength() int {
+	if c.OutputLength <= 0 {
+		return OutputLength
+	}
+	return c.OutputLength
+}
+
+func (c *Client) send(ctx context.Context, results []PassiveResult) error {
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", c.Address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	init := make([]byte, ivLength+4)
+	if _, err := io.ReadFull(conn, init); err != nil {
+		return fmt.Errorf("reading initialisation packet: %s", err.Error())
+	}
+	crypter, err := newCrypter(c.Encryption, init[:ivLength], c.Password)
+	if err != nil {
+		return err
+	}
+	timestamp := binary.BigEndian.Uint32(init[ivLength:])
+
+	for _, r := range results {
+		data, err := encodePacket(r, timestamp, c.outputLength())
+		if err != nil {
+			return err
+		}
+		crypter.encrypt(data)
+		if _, err := conn.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendBatches sends the results in batches of the size, each batch is retried on errors
+func sendBatches(ctx context.Context, results []PassiveResult, size int, retries int, delay time.Duration,
+	send func(ctx context.Context, batch []PassiveResult) error) error {
+	if size <= 0 {
+		size = len(results)
+	}
+	for len(results) > 0 {
+		n := size
+		if n > len(results) {
+			n = len(results)
+		}
+
+		err := send(ctx, results[:n])
+		for attempt := 0; err != nil && attempt < retries; attempt++ {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			err = send(ctx, results[:n])
+		}
+		if err != nil {
+			return err
+		}
+		results = results[n:]
+	}
+	return nil
+}
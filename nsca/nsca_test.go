@@ -0,0 +1,288 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nsca
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/rand"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+type received struct {
+	timestamp uint32
+	code      int
+	host      string
+	service   string
+	output    string
+}
+
+// fakeServer is an NSCA server decrypting and collecting the packets, the first
+// failures connections are closed before sending the initialisation packet
+type fakeServer struct {
+	listener     net.Listener
+	method       Encryption
+	password     string
+	outputLength int
+	failures     int
+
+	mu          sync.Mutex
+	connections int
+	packets     []received
+	errors      []error
+}
+
+func startFakeServer(t *testing.T, method Encryption, password string, outputLength int, failures int) *fakeServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	s := &fakeServer{listener: l, method: method, password: password, outputLength: outputLength, failures: failures}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.serve(conn)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+
+	s.mu.Lock()
+	s.connections++
+	fail := s.connections <= s.failures
+	s.mu.Unlock()
+	if fail {
+		return
+	}
+
+	init := make([]byte, ivLength+4)
+	rand.Read(init[:ivLength])
+	binary.BigEndian.PutUint32(init[ivLength:], 1700000000)
+	conn.Write(init)
+	crypter, _ := newCrypter(s.method, init[:ivLength], s.password)
+
+	for {
+		data := make([]byte, packetLength(s.outputLength))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+		crypter.decrypt(data)
+
+		crc := binary.BigEndian.Uint32(data[4:])
+		binary.BigEndian.PutUint32(data[4:], 0)
+		s.mu.Lock()
+		if crc32.ChecksumIEEE(data) != crc || binary.BigEndian.Uint16(data) != packetVersion {
+			s.errors = append(s.errors, io.ErrUnexpectedEOF)
+		}
+		s.packets = append(s.packets, received{
+			timestamp: binary.BigEndian.Uint32(data[8:]),
+			code:      int(binary.BigEndian.Uint16(data[12:])),
+			host:      cString(data[headerLength : headerLength+hostLength]),
+			service:   cString(data[headerLength+hostLength : headerLength+hostLength+serviceLength]),
+			output:    cString(data[headerLength+hostLength+serviceLength:]),
+		})
+		s.mu.Unlock()
+	}
+}
+
+func (s *fakeServer) received(t *testing.T, n int) []received {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		s.mu.Lock()
+		if len(s.packets) >= n {
+			defer s.mu.Unlock()
+			if len(s.errors) > 0 {
+				t.Errorf("Received %d invalid packets", len(s.errors))
+			}
+			return s.packets
+		}
+		s.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expecting %d packets", n)
+	return nil
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func testResults(n int) []PassiveResult {
+	var results []PassiveResult
+	for i := 0; i < n; i++ {
+		results = append(results, PassiveResult{Host: "web1", Service: "http", Result: icinga.Result{
+			Code:       icinga.ExitWarning,
+			Message:    "slow",
+			LongOutput: "line 1\nline 2",
+			PerfData:   []perfdata.PerformanceData{*perfdata.CreatePerformanceData("time", 2, "s")},
+		}})
+	}
+	return results
+}
+
+func TestClientSend(t *testing.T) {
+	for _, method := range []Encryption{EncryptionNone, EncryptionXOR, EncryptionAES} {
+		s := startFakeServer(t, method, "secret", OutputLength, 0)
+		c := CreateClient(s.listener.Addr().String())
+		c.Encryption = method
+		c.Password = "secret"
+		c.BatchSize = 2
+
+		results := append(testResults(4), PassiveResult{Host: "db1", Result: icinga.Result{Code: icinga.ExitCritical, Message: "down"}})
+		if err := c.Send(context.Background(), results...); err != nil {
+			t.Fatalf("Unexpected error occured: %s", err.Error())
+		}
+
+		packets := s.received(t, 5)
+		s.mu.Lock()
+		connections := s.connections
+		s.mu.Unlock()
+		if connections != 3 {
+			t.Errorf("Expecting 3 connections, got %d", connections)
+		}
+		want := received{1700000000, 1, "web1", "http", `WARNING - slow | 'time'=2s;;;;\nline 1\nline 2`}
+		if packets[0] != want {
+			t.Errorf("Unexpected packet of encryption %d: %+v", method, packets[0])
+		}
+		want = received{1700000000, 2, "db1", "", "CRITICAL - down"}
+		if packets[4] != want {
+			t.Errorf("Unexpected packet of encryption %d: %+v", method, packets[4])
+		}
+	}
+}
+
+func TestClientOutputLength(t *testing.T) {
+	s := startFakeServer(t, EncryptionXOR, "", OutputLengthLegacy, 0)
+	c := CreateClient(s.listener.Addr().String())
+	c.Encryption = EncryptionXOR
+	c.OutputLength = OutputLengthLegacy
+
+	r := PassiveResult{Host: "web1", Service: "log", Result: icinga.Result{Message: "many lines",
+		LongOutput: strings.Repeat("a long line of output\n", 100)}}
+	if err := c.Send(context.Background(), r); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	output := s.received(t, 1)[0].output
+	if len(output) > OutputLengthLegacy-1 || !strings.HasSuffix(output, `\n`+icinga.DefaultTruncationMarker) {
+		t.Errorf("Expecting truncated output, got %d bytes: %s", len(output), output)
+	}
+}
+
+func TestClientZeroValue(t *testing.T) {
+	s := startFakeServer(t, EncryptionNone, "", OutputLength, 0)
+	c := &Client{Address: s.listener.Addr().String()}
+
+	if err := c.Send(context.Background(), testResults(1)...); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	want := received{1700000000, 1, "web1", "http", `WARNING - slow | 'time'=2s;;;;\nline 1\nline 2`}
+	if packet := s.received(t, 1)[0]; packet != want {
+		t.Errorf("Unexpected packet: %+v", packet)
+	}
+}
+
+func TestFormatOutputCut(t *testing.T) {
+	output := formatOutput(icinga.Result{Message: "xx" + strings.Repeat("ä", 100)}, 50)
+	if len(output) != 49 || !utf8.ValidString(output) {
+		t.Errorf("Expecting valid UTF-8 cut to 49 bytes, got %q", output)
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	s := startFakeServer(t, EncryptionNone, "", OutputLength, 2)
+	c := CreateClient(s.listener.Addr().String())
+	c.RetryDelay = time.Millisecond
+
+	if err := c.Send(context.Background(), testResults(1)...); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	s.received(t, 1)
+
+	s.mu.Lock()
+	s.failures = s.connections + 3
+	s.mu.Unlock()
+	if err := c.Send(context.Background(), testResults(1)...); err == nil {
+		t.Errorf("Expecting error after %d retries", c.Retries)
+	}
+}
+
+func TestClientInvalidResult(t *testing.T) {
+	c := CreateClient("127.0.0.1:1")
+	for _, r := range []PassiveResult{{}, {Host: strings.Repeat("h", 64)}, {Host: "h", Service: strings.Repeat("s", 128)}} {
+		if err := c.Send(context.Background(), r); err == nil {
+			t.Errorf("Expecting error for %+v", r)
+		}
+	}
+}
+
+func TestCrypterAES(t *testing.T) {
+	iv := make([]byte, ivLength)
+	rand.Read(iv)
+	plain := []byte("packet one, packet two")
+
+	data := append([]byte{}, plain...)
+	c, _ := newCrypter(EncryptionAES, iv, "secret")
+	c.encrypt(data[:10])
+	c.encrypt(data[10:])
+
+	// the first byte is encrypted with the first byte of the encrypted iv
+	key := make([]byte, aesKeyLength)
+	copy(key, "secret")
+	block, _ := aes.NewCipher(key)
+	stream := make([]byte, aes.BlockSize)
+	block.Encrypt(stream, iv[:aes.BlockSize])
+	if data[0] != plain[0]^stream[0] {
+		t.Errorf("Unexpected first byte %x", data[0])
+	}
+
+	d, _ := newCrypter(EncryptionAES, iv, "secret")
+	d.decrypt(data)
+	if !bytes.Equal(data, plain) {
+		t.Errorf("Unexpected decrypted data %q", data)
+	}
+}
+
+func TestCrypterUnsupported(t *testing.T) {
+	if _, err := newCrypter(Encryption(3), make([]byte, ivLength), ""); err == nil ||
+		err.Error() != "unsupported encryption method 3" {
+		t.Errorf("Expecting unsupported encryption error, got %v", err)
+	}
+}
@@ -0,0 +1,463 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nsca
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+)
+
+/*
+ * TLS 1.2 with pre-shared keys (RFC 4279, RFC 5487) as required by NSCA-ng, crypto/tls
+ * does not support it. Only full handshakes with the cipher suites below are implemented,
+ * without resumption, renegotiation and extensions.
+ */
+
+const (
+	recordChangeCipherSpec = 20
+	recordAlert            = 21
+	recordHandshake        = 22
+	recordApplicationData  = 23
+
+	handshakeClientHello       = 1
+	handshakeServerHello       = 2
+	handshakeServerKeyExchange = 12
+	handshakeServerHelloDone   = 14
+	handshakeClientKeyExchange = 16
+	handshakeFinished          = 20
+
+	alertCloseNotify = 0
+
+	tlsVersion12 = 0x0303
+	// maxPlaintext is the maximum length of the data in one record
+	maxPlaintext = 16384
+	// maxCiphertext is the maximum length of an encrypted record
+	maxCiphertext = maxPlaintext + 2048
+	// scsvRenegotiation signals secure renegotiation without the extension
+	scsvRenegotiation = 0x00ff
+)
+
+// pskSuite is a cipher suite with AES and the PRF of TLS 1.2
+type pskSuite struct {
+	id        uint16
+	keyLength int
+	aead      bool
+}
+
+// pskSuites in order of preference
+var pskSuites = []pskSuite{
+	{0x00a8, 16, true},  // TLS_PSK_WITH_AES_128_GCM_SHA256
+	{0x008d, 32, false}, // TLS_PSK_WITH_AES_256_CBC_SHA
+	{0x008c, 16, false}, // TLS_PSK_WITH_AES_128_CBC_SHA
+}
+
+func findSuite(id uint16) (pskSuite, bool) {
+	for _, s := range pskSuites {
+		if s.id == id {
+			return s, true
+		}
+	}
+	return pskSuite{}, false
+}
+
+// DialPSK returns a DialFunc connecting to the NSCA-ng server at the address, e.g.
+// "icinga:5668", with TLS 1.2 and the identity and password of the client as pre-shared key
+func DialPSK(address string, identity string, password string) DialFunc {
+	return func(ctx context.Context) (net.Conn, error) {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		c, err := pskClient(conn, identity, []byte(password))
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// halfConn encrypts or decrypts the records of one direction
+type halfConn struct {
+	macKey  []byte
+	block   cipher.Block
+	aead    cipher.AEAD
+	fixedIV []byte
+	seq     uint64
+}
+
+func newHalfConn(suite pskSuite, macKey []byte, key []byte, iv []byte) *halfConn {
+	// the key length is fixed by the suite, so creating the cipher cannot fail
+	block, _ := aes.NewCipher(key)
+	h := &halfConn{macKey: macKey, block: block, fixedIV: iv}
+	if suite.aead {
+		h.aead, _ = cipher.NewGCM(block)
+	}
+	return h
+}
+
+// additionalData returns the sequence number and the header of the plaintext
+func (h *halfConn) additionalData(typ byte, length int) []byte {
+	data := make([]byte, 13)
+	binary.BigEndian.PutUint64(data, h.seq)
+	data[8] = typ
+	binary.BigEndian.PutUint16(data[9:], tlsVersion12)
+	binary.BigEndian.PutUint16(data[11:], uint16(length))
+	h.seq++
+	return data
+}
+
+func (h *halfConn) seal(typ byte, data []byte) []byte {
+	ad := h.additionalData(typ, len(data))
+	if h.aead != nil {
+		// the sequence number is the explicit part of the nonce
+		nonce := append(append([]byte{}, h.fixedIV...), ad[:8]...)
+		return h.aead.Seal(append([]byte{}, ad[:8]...), nonce, data, ad)
+	}
+
+	mac := hmac.New(sha1.New, h.macKey)
+	mac.Write(ad)
+	mac.Write(data)
+	plaintext := mac.Sum(append([]byte{}, data...))
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	for i := 0; i < padding; i++ {
+		plaintext = append(plaintext, byte(padding-1))
+	}
+
+	record := make([]byte, aes.BlockSize+len(plaintext))
+	rand.Read(record[:aes.BlockSize])
+	cipher.NewCBCEncrypter(h.block, record[:aes.BlockSize]).CryptBlocks(record[aes.BlockSize:], plaintext)
+	return record
+}
+
+func (h *halfConn) open(typ byte, record []byte) ([]byte, error) {
+	errBadRecord := errors.New("tls: bad record MAC")
+	if h.aead != nil {
+		if len(record) < 8+h.aead.Overhead() {
+			return nil, errBadRecord
+		}
+		nonce := append(append([]byte{}, h.fixedIV...), record[:8]...)
+		ad := h.additionalData(typ, len(record)-8-h.aead.Overhead())
+		data, err := h.aead.Open(nil, nonce, record[8:], ad)
+		if err != nil {
+			return nil, errBadRecord
+		}
+		return data, nil
+	}
+
+	if len(record) < aes.BlockSize+2*aes.BlockSize || len(record)%aes.BlockSize != 0 {
+		return nil, errBadRecord
+	}
+	plaintext := make([]byte, len(record)-aes.BlockSize)
+	cipher.NewCBCDecrypter(h.block, record[:aes.BlockSize]).CryptBlocks(plaintext, record[aes.BlockSize:])
+	padding := int(plaintext[len(plaintext)-1]) + 1
+	if padding+sha1.Size > len(plaintext) {
+		return nil, errBadRecord
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding-1 {
+			return nil, errBadRecord
+		}
+	}
+	data := plaintext[:len(plaintext)-padding-sha1.Size]
+
+	mac := hmac.New(sha1.New, h.macKey)
+	mac.Write(h.additionalData(typ, len(data)))
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), plaintext[len(data):len(data)+sha1.Size]) {
+		return nil, errBadRecord
+	}
+	return data, nil
+}
+
+// pskConn is a TLS connection after the handshake
+type pskConn struct {
+	net.Conn
+	in  *halfConn
+	out *halfConn
+	// input is application data not read yet, handshake a partial handshake message
+	input     []byte
+	handshake []byte
+}
+
+func (c *pskConn) readRecord() (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(c.Conn, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[3:]))
+	if header[1] != 3 || length > maxCiphertext {
+		return 0, nil, errors.New("tls: invalid record")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.Conn, data); err != nil {
+		return 0, nil, err
+	}
+	if c.in != nil {
+		var err error
+		if data, err = c.in.open(header[0], data); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if header[0] == recordAlert {
+		if len(data) == 2 && data[1] == alertCloseNotify {
+			return 0, nil, io.EOF
+		}
+		if len(data) == 2 {
+			return 0, nil, fmt.Errorf("tls: received alert %d", data[1])
+		}
+		return 0, nil, errors.New("tls: invalid alert")
+	}
+	return header[0], data, nil
+}
+
+func (c *pskConn) writeRecord(typ byte, data []byte) error {
+	for {
+		n := len(data)
+		if n > maxPlaintext {
+			n = maxPlaintext
+		}
+		fragment := data[:n]
+		if c.out != nil {
+			fragment = c.out.seal(typ, fragment)
+		}
+		record := []byte{typ, 3, 3, byte(len(fragment) >> 8), byte(len(fragment))}
+		if _, err := c.Conn.Write(append(record, fragment...)); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// readHandshake returns the next handshake message and adds it to the transcript
+func (c *pskConn) readHandshake(transcript hash.Hash) (byte, []byte, error) {
+	for len(c.handshake) < 4 || len(c.handshake) < 4+int(binary.BigEndian.Uint32(c.handshake)&0xffffff) {
+		typ, data, err := c.readRecord()
+		if err != nil {
+			return 0, nil, err
+		}
+		if typ != recordHandshake {
+			return 0, nil, fmt.Errorf("tls: unexpected record type %d", typ)
+		}
+		c.handshake = append(c.handshake, data...)
+	}
+	length := 4 + int(binary.BigEndian.Uint32(c.handshake)&0xffffff)
+	message := c.handshake[:length]
+	c.handshake = c.handshake[length:]
+	transcript.Write(message)
+	return message[0], message[4:], nil
+}
+
+func (c *pskConn) writeHandshake(transcript hash.Hash, typ byte, body []byte) error {
+	message := append([]byte{typ, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+	transcript.Write(message)
+	return c.writeRecord(recordHandshake, message)
+}
+
+// readChangeCipherSpec reads the ChangeCipherSpec of the peer and enables decryption
+func (c *pskConn) readChangeCipherSpec(in *halfConn) error {
+	typ, data, err := c.readRecord()
+	if err != nil {
+		return err
+	}
+	if typ != recordChangeCipherSpec || len(c.handshake) > 0 || !bytes.Equal(data, []byte{1}) {
+		return errors.New("tls: expecting ChangeCipherSpec")
+	}
+	c.in = in
+	return nil
+}
+
+// writeChangeCipherSpec sends the ChangeCipherSpec and enables encryption
+func (c *pskConn) writeChangeCipherSpec(out *halfConn) error {
+	if err := c.writeRecord(recordChangeCipherSpec, []byte{1}); err != nil {
+		return err
+	}
+	c.out = out
+	return nil
+}
+
+func (c *pskConn) Read(b []byte) (int, error) {
+	for len(c.input) == 0 {
+		typ, data, err := c.readRecord()
+		if err != nil {
+			return 0, err
+		}
+		if typ != recordApplicationData {
+			return 0, fmt.Errorf("tls: unexpected record type %d", typ)
+		}
+		c.input = data
+	}
+	n := copy(b, c.input)
+	c.input = c.input[n:]
+	return n, nil
+}
+
+func (c *pskConn) Write(b []byte) (int, error) {
+	if err := c.writeRecord(recordApplicationData, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close sends a close_notify alert and closes the connection
+func (c *pskConn) Close() error {
+	c.writeRecord(recordAlert, []byte{1, alertCloseNotify})
+	return c.Conn.Close()
+}
+
+// pskClient performs the handshake of the client and returns the established connection
+func pskClient(conn net.Conn, identity string, key []byte) (*pskConn, error) {
+	c := &pskConn{Conn: conn}
+	transcript := sha256.New()
+
+	clientRandom := make([]byte, 32)
+	rand.Read(clientRandom)
+	hello := append([]byte{3, 3}, clientRandom...)
+	hello = append(hello, 0)
+	hello = binary.BigEndian.AppendUint16(hello, uint16(2*len(pskSuites)+2))
+	for _, s := range pskSuites {
+		hello = binary.BigEndian.AppendUint16(hello, s.id)
+	}
+	hello = binary.BigEndian.AppendUint16(hello, scsvRenegotiation)
+	hello = append(hello, 1, 0)
+	if err := c.writeHandshake(transcript, handshakeClientHello, hello); err != nil {
+		return nil, err
+	}
+
+	typ, body, err := c.readHandshake(transcript)
+	if err != nil {
+		return nil, err
+	}
+	if typ != handshakeServerHello || len(body) < 35 || len(body) < 35+int(body[34])+3 {
+		return nil, errors.New("tls: expecting ServerHello")
+	}
+	if binary.BigEndian.Uint16(body) != tlsVersion12 {
+		return nil, fmt.Errorf("tls: unsupported version %x", binary.BigEndian.Uint16(body))
+	}
+	serverRandom := body[2:34]
+	rest := body[35+int(body[34]):]
+	suite, ok := findSuite(binary.BigEndian.Uint16(rest))
+	if !ok || rest[2] != 0 {
+		return nil, errors.New("tls: server selected an unsupported cipher suite")
+	}
+
+	// the identity hint of the ServerKeyExchange is not used by NSCA-ng
+	for typ != handshakeServerHelloDone {
+		if typ, _, err = c.readHandshake(transcript); err != nil {
+			return nil, err
+		}
+		if typ != handshakeServerKeyExchange && typ != handshakeServerHelloDone {
+			return nil, fmt.Errorf("tls: unexpected handshake message %d", typ)
+		}
+	}
+
+	exchange := binary.BigEndian.AppendUint16(nil, uint16(len(identity)))
+	if err := c.writeHandshake(transcript, handshakeClientKeyExchange, append(exchange, identity...)); err != nil {
+		return nil, err
+	}
+
+	master := masterSecret(key, clientRandom, serverRandom)
+	client, server := keys(suite, master, clientRandom, serverRandom)
+	if err := c.writeChangeCipherSpec(client); err != nil {
+		return nil, err
+	}
+	finished := prf(master, "client finished", transcript.Sum(nil), 12)
+	if err := c.writeHandshake(transcript, handshakeFinished, finished); err != nil {
+		return nil, err
+	}
+
+	if err := c.readChangeCipherSpec(server); err != nil {
+		return nil, err
+	}
+	want := prf(master, "server finished", transcript.Sum(nil), 12)
+	if typ, body, err = c.readHandshake(transcript); err != nil {
+		return nil, err
+	}
+	if typ != handshakeFinished || !hmac.Equal(body, want) {
+		return nil, errors.New("tls: invalid Finished of the server")
+	}
+	return c, nil
+}
+
+// masterSecret returns the master secret of the pre-shared key
+func masterSecret(key []byte, clientRandom []byte, serverRandom []byte) []byte {
+	premaster := binary.BigEndian.AppendUint16(nil, uint16(len(key)))
+	premaster = append(premaster, make([]byte, len(key))...)
+	premaster = binary.BigEndian.AppendUint16(premaster, uint16(len(key)))
+	premaster = append(premaster, key...)
+
+	return prf(premaster, "master secret", append(append([]byte{}, clientRandom...), serverRandom...), 48)
+}
+
+// keys returns the encryption of the client and of the server
+func keys(suite pskSuite, master []byte, clientRandom []byte, serverRandom []byte) (*halfConn, *halfConn) {
+	macLength, ivLength := sha1.Size, 0
+	if suite.aead {
+		macLength, ivLength = 0, 4
+	}
+	block := prf(master, "key expansion", append(append([]byte{}, serverRandom...), clientRandom...),
+		2*(macLength+suite.keyLength+ivLength))
+
+	next := func(n int) []byte {
+		b := block[:n]
+		block = block[n:]
+		return b
+	}
+	clientMAC, serverMAC := next(macLength), next(macLength)
+	clientKey, serverKey := next(suite.keyLength), next(suite.keyLength)
+	clientIV, serverIV := next(ivLength), next(ivLength)
+
+	return newHalfConn(suite, clientMAC, clientKey, clientIV), newHalfConn(suite, serverMAC, serverKey, serverIV)
+}
+
+// prf is the pseudorandom function of TLS 1.2 with SHA-256
+func prf(secret []byte, label string, seed []byte, length int) []byte {
+	seed = append([]byte(label), seed...)
+	var result []byte
+	a := seed
+	for len(result) < length {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(a)
+		a = mac.Sum(nil)
+
+		mac = hmac.New(sha256.New, secret)
+		mac.Write(a)
+		mac.Write(seed)
+		result = mac.Sum(result)
+	}
+	return result[:length]
+}
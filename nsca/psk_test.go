@@ -0,0 +1,157 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package nsca
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// pskServer performs the handshake of a server selecting the suite and returns the
+// established connection and the identity of the client
+func pskServer(conn net.Conn, key []byte, suiteID uint16) (*pskConn, string, error) {
+	c := &pskConn{Conn: conn}
+	transcript := sha256.New()
+
+	typ, body, err := c.readHandshake(transcript)
+	if err != nil {
+		return nil, "", err
+	}
+	if typ != handshakeClientHello || binary.BigEndian.Uint16(body) != tlsVersion12 {
+		return nil, "", errors.New("expecting ClientHello of TLS 1.2")
+	}
+	clientRandom := body[2:34]
+	rest := body[35+int(body[34]):]
+	suites := rest[2 : 2+binary.BigEndian.Uint16(rest)]
+	suite, offered := pskSuite{}, false
+	for i := 0; i < len(suites); i += 2 {
+		if binary.BigEndian.Uint16(suites[i:]) == suiteID {
+			suite, offered = findSuite(suiteID)
+		}
+	}
+	if !offered {
+		return nil, "", errors.New("suite not offered")
+	}
+
+	serverRandom := make([]byte, 32)
+	rand.Read(serverRandom)
+	hello := append(append([]byte{3, 3}, serverRandom...), 0)
+	hello = append(binary.BigEndian.AppendUint16(hello, suiteID), 0)
+	c.writeHandshake(transcript, handshakeServerHello, hello)
+	c.writeHandshake(transcript, handshakeServerKeyExchange, []byte{0, 0})
+	c.writeHandshake(transcript, handshakeServerHelloDone, nil)
+
+	if typ, body, err = c.readHandshake(transcript); err != nil {
+		return nil, "", err
+	}
+	if typ != handshakeClientKeyExchange {
+		return nil, "", errors.New("expecting ClientKeyExchange")
+	}
+	identity := string(body[2 : 2+binary.BigEndian.Uint16(body)])
+
+	master := masterSecret(key, clientRandom, serverRandom)
+	client, server := keys(suite, master, clientRandom, serverRandom)
+	if err := c.readChangeCipherSpec(client); err != nil {
+		return nil, "", err
+	}
+	want := prf(master, "client finished", transcript.Sum(nil), 12)
+	typ, body, err = c.readHandshake(transcript)
+	if err != nil || typ != handshakeFinished || !hmac.Equal(body, want) {
+		// decrypt_error
+		c.in = nil
+		c.writeRecord(recordAlert, []byte{2, 51})
+		return nil, "", errors.New("invalid Finished of the client")
+	}
+
+	c.writeChangeCipherSpec(server)
+	c.writeHandshake(transcript, handshakeFinished, prf(master, "server finished", transcript.Sum(nil), 12))
+	return c, identity, nil
+}
+
+// startPSKServer accepts one connection with the suite and echos the data sent
+func startPSKServer(t *testing.T, key string, suiteID uint16) (string, chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	t.Cleanup(func() { l.Close() })
+
+	identities := make(chan string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		c, identity, err := pskServer(conn, []byte(key), suiteID)
+		identities <- identity
+		if err == nil {
+			io.Copy(c, c)
+		}
+	}()
+	return l.Addr().String(), identities
+}
+
+func TestPRF(t *testing.T) {
+	secret, _ := hex.DecodeString("9bbe436ba940f017b17652849a71db35")
+	seed, _ := hex.DecodeString("a0ba9f936cda311827a6f796ffd5198c")
+	if got := hex.EncodeToString(prf(secret, "test label", seed, 16)); got != "e3f229ba727be17b8d122620557cd453" {
+		t.Errorf("Unexpected PRF output %s", got)
+	}
+}
+
+func TestDialPSK(t *testing.T) {
+	data := []byte(strings.Repeat("0123456789", 4000))
+	for _, suite := range pskSuites {
+		addr, identities := startPSKServer(t, "secret", suite.id)
+		conn, err := DialPSK(addr, "web1", "secret")(context.Background())
+		if err != nil {
+			t.Fatalf("Unexpected error of suite %04x: %s", suite.id, err.Error())
+		}
+		if identity := <-identities; identity != "web1" {
+			t.Errorf("Unexpected identity %q", identity)
+		}
+
+		// records exceed the maximum length and are fragmented
+		if _, err := conn.Write(data); err != nil {
+			t.Fatalf("Unexpected error of suite %04x: %s", suite.id, err.Error())
+		}
+		echo := make([]byte, len(data))
+		if _, err := io.ReadFull(conn, echo); err != nil || !bytes.Equal(echo, data) {
+			t.Errorf("Unexpected echo of suite %04x: %v", suite.id, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestDialPSKWrongKey(t *testing.T) {
+	addr, _ := startPSKServer(t, "secret", pskSuites[0].id)
+	_, err := DialPSK(addr, "web1", "wrong")(context.Background())
+	if err == nil || err.Error() != "tls: received alert 51" {
+		t.Errorf("Expecting alert, got %v", err)
+	}
+}
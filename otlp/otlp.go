@@ -0,0 +1,221 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// DefaultEndpoint of an OpenTelemetry collector receiving OTLP/HTTP
+const DefaultEndpoint = "http://localhost:4318/v1/metrics"
+
+const scopeName = "github.com/marshei/icinga_plugins/otlp"
+
+// UCUM units of the normalised performance data units
+var units = map[string]string{
+	perfdata.UnitNone:    "1",
+	perfdata.UnitSeconds: "s",
+	perfdata.UnitBytes:   "By",
+	perfdata.UnitPercent: "%",
+	perfdata.UnitCounter: "{count}",
+}
+
+/*
+ * OTLP JSON encoding of the metrics service request
+ */
+
+type anyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type numberDataPoint struct {
+	Attributes   []keyValue `json:"attributes"`
+	TimeUnixNano string     `json:"timeUnixNano"`
+	AsDouble     *float64   `json:"asDouble,omitempty"`
+	AsInt        *string    `json:"asInt,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type metric struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit"`
+	Gauge       gauge  `json:"gauge"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type exportRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+func attribute(key string, value string) keyValue {
+	return keyValue{Key: key, Value: anyValue{StringValue: &value}}
+}
+
+// Encode returns the result as OTLP JSON metrics service request. The exit code is the gauge
+// icinga.check.state, each performance data entry a gauge icinga.perfdata.<metric> in its
+// base unit. All data points have the attributes icinga.check and icinga.state, labels like
+// "db1::connections" also icinga.instance. Unavailable values are skipped.
+func Encode(result icinga.Result, source perfdata.Source) ([]byte, error) {
+	t := source.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	timestamp := strconv.FormatInt(t.UnixNano(), 10)
+	common := []keyValue{
+		attribute("icinga.check", source.Check),
+		attribute("icinga.state", result.Code.String()),
+	}
+
+	state := strconv.Itoa(int(result.Code))
+	metrics := []metric{{
+		Name:        "icinga.check.state",
+		Description: "Exit code of the check: 0 OK, 1 WARNING, 2 CRITICAL, 3 UNKNOWN",
+		Unit:        "1",
+		Gauge:       gauge{DataPoints: []numberDataPoint{{Attributes: common, TimeUnixNano: timestamp, AsInt: &state}}},
+	}}
+
+	index := make(map[string]int)
+	for _, pd := range result.PerfData {
+		value, unit := perfdata.NormaliseUnit(pd.Value, pd.UOM)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		attributes := append([]keyValue{}, common...)
+		if parts := perfdata.SplitLabel(pd.Label); len(parts) > 1 {
+			attributes = append(attributes, attribute("icinga.instance", perfdata.JoinLabel(parts[:len(parts)-1]...)))
+		}
+
+		name := "icinga.perfdata." + perfdata.PrometheusName(perfdata.Metric(pd.Label))
+		ucum, ok := units[unit]
+		if !ok {
+			ucum = unit
+		}
+
+		v := value
+		point := numberDataPoint{Attributes: attributes, TimeUnixNano: timestamp, AsDouble: &v}
+		if i, ok := index[name+" "+ucum]; ok {
+			metrics[i].Gauge.DataPoints = append(metrics[i].Gauge.DataPoints, point)
+			continue
+		}
+		index[name+" "+ucum] = len(metrics)
+		metrics = append(metrics, metric{Name: name, Unit: ucum, Gauge: gauge{DataPoints: []numberDataPoint{point}}})
+	}
+
+	request := exportRequest{ResourceMetrics: []resourceMetrics{{
+		Resource: resource{Attributes: []keyValue{
+			attribute("host.name", source.Host),
+			attribute("service.name", source.Check),
+		}},
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: metrics}},
+	}}}
+
+	return json.Marshal(request)
+}
+
+/*
+ * OTLP/HTTP exporter
+ */
+
+// Exporter sends check results to an OpenTelemetry collector via OTLP/HTTP
+type Exporter struct {
+	Endpoint string
+	Host     string
+	Check    string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+// CreateExporter creates and returns a new Exporter sending the results of the check
+// identified by host and name to the endpoint, e.g. DefaultEndpoint
+func CreateExporter(endpoint string, host string, check string) *Exporter {
+	e := new(Exporter)
+	e.Endpoint = endpoint
+	e.Host = host
+	e.Check = check
+	e.Headers = make(map[string]string)
+	e.Client = &http.Client{Timeout: 10 * time.Second}
+
+	return e
+}
+
+// Export sends the result of a check run at the given time
+func (e *Exporter) Export(ctx context.Context, result icinga.Result, t time.Time) error {
+	body, err := Encode(result, perfdata.Source{Host: e.Host, Check: e.Check, Time: t})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("export failed: %s %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,127 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func testResult() icinga.Result {
+	return icinga.Result{
+		Code:    icinga.ExitCritical,
+		Message: "slow",
+		PerfData: []perfdata.PerformanceData{
+			*perfdata.CreatePerformanceData("time", 250, "ms"),
+			*perfdata.CreatePerformanceData("db1::connections", 8, ""),
+			*perfdata.CreatePerformanceData("db2::connections", 3, ""),
+			*perfdata.CreateUnknownPerformanceData("missing", ""),
+		},
+	}
+}
+
+// collector is a local stand-in for an OpenTelemetry collector
+func collector(t *testing.T, status int, received *exportRequest) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/metrics" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Unexpected content type %s", ct)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Unexpected authorization %s", auth)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, received); err != nil {
+			t.Errorf("Invalid request: %s", err.Error())
+		}
+		w.WriteHeader(status)
+		io.WriteString(w, "{}")
+	}))
+}
+
+func TestExport(t *testing.T) {
+	var received exportRequest
+	server := collector(t, http.StatusOK, &received)
+	defer server.Close()
+
+	e := CreateExporter(server.URL+"/v1/metrics", "web1", "http")
+	e.Headers["Authorization"] = "Bearer secret"
+	if err := e.Export(context.Background(), testResult(), time.Unix(1700000000, 0)); err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	if len(received.ResourceMetrics) != 1 {
+		t.Fatalf("Expecting one resource, got %d", len(received.ResourceMetrics))
+	}
+	rm := received.ResourceMetrics[0]
+	if *rm.Resource.Attributes[0].Value.StringValue != "web1" {
+		t.Errorf("Unexpected resource attributes %v", rm.Resource.Attributes)
+	}
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	if len(metrics) != 3 {
+		t.Fatalf("Expecting %d metrics, got %d", 3, len(metrics))
+	}
+
+	state := metrics[0]
+	if state.Name != "icinga.check.state" || *state.Gauge.DataPoints[0].AsInt != "2" ||
+		state.Gauge.DataPoints[0].TimeUnixNano != "1700000000000000000" {
+		t.Errorf("Unexpected state metric %+v", state)
+	}
+
+	rt := metrics[1]
+	if rt.Name != "icinga.perfdata.time" || rt.Unit != "s" || *rt.Gauge.DataPoints[0].AsDouble != 0.25 {
+		t.Errorf("Unexpected time metric %+v", rt)
+	}
+	attributes := rt.Gauge.DataPoints[0].Attributes
+	if attributes[0].Key != "icinga.check" || attributes[1].Key != "icinga.state" || *attributes[1].Value.StringValue != "CRITICAL" {
+		t.Errorf("Unexpected attributes %+v", attributes)
+	}
+
+	conn := metrics[2]
+	if conn.Name != "icinga.perfdata.connections" || conn.Unit != "1" || len(conn.Gauge.DataPoints) != 2 {
+		t.Fatalf("Unexpected connections metric %+v", conn)
+	}
+	instance := conn.Gauge.DataPoints[1].Attributes[2]
+	if instance.Key != "icinga.instance" || *instance.Value.StringValue != "db2" {
+		t.Errorf("Unexpected instance attribute %+v", instance)
+	}
+}
+
+func TestExportError(t *testing.T) {
+	var received exportRequest
+	server := collector(t, http.StatusBadRequest, &received)
+	defer server.Close()
+
+	e := CreateExporter(server.URL+"/v1/metrics", "web1", "http")
+	e.Headers["Authorization"] = "Bearer secret"
+	err := e.Export(context.Background(), testResult(), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "export failed: 400 Bad Request {}") {
+		t.Errorf("Expecting an export error, got %v", err)
+	}
+}
@@ -18,64 +18,213 @@ package icinga
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	"github.com/marshei/icinga_plugins/perfdata"
 )
 
-func Print(message string, code ExitCode) ExitCode {
-	fmt.Printf("%s - %s\n", code.String(), message)
+// Output limits of Icinga and NRPE, the NRPE buffers include a terminating null byte
+const (
+	MaxLengthNRPEv2 = 1024 - 1
+	MaxLengthNRPEv3 = 64*1024 - 1
+)
+
+// DefaultTruncationMarker is appended as last line to truncated output
+const DefaultTruncationMarker = "[output truncated]"
+
+// Printer renders the plugin output to a writer
+type Printer struct {
+	Writer io.Writer
+	// MaxLength limits the length of the output in bytes, 0 means unlimited
+	MaxLength        int
+	TruncationMarker string
+	// Sanitise replaces characters breaking the output in messages, see SanitiseSummary
+	Sanitise bool
+}
+
+// stdout writes to the current os.Stdout, even if it is replaced after initialisation
+type stdout struct{}
+
+func (stdout) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
+
+// DefaultPrinter is used by the package level print functions and writes to stdout
+var DefaultPrinter = CreatePrinter(stdout{})
+
+// CreatePrinter creates and returns a new Printer writing to the given writer
+func CreatePrinter(w io.Writer) *Printer {
+	p := new(Printer)
+	p.Writer = w
+	p.TruncationMarker = DefaultTruncationMarker
+	p.Sanitise = true
+
+	return p
+}
+
+func (p *Printer) Print(message string, code ExitCode) ExitCode {
+	return p.PrintWithLongOutput(message, "", code, nil)
+}
+
+func (p *Printer) PrintWithPerformanceData(message string, code ExitCode, perfDataList []perfdata.PerformanceData) ExitCode {
+	return p.PrintWithLongOutput(message, "", code, perfDataList)
+}
+
+// PrintWithLongOutput prints the summary line with the performance data followed by the
+// lines of the long output. If the output exceeds MaxLength the long output is truncated
+// first, then whole performance data entries are dropped and the truncation marker is
+// appended. The summary line is never truncated.
+func (p *Printer) PrintWithLongOutput(message string, longOutput string, code ExitCode,
+	perfDataList []perfdata.PerformanceData) ExitCode {
+	io.WriteString(p.Writer, p.Render(message, longOutput, code, perfDataList))
 	return code
 }
 
-func PrintWithPerformanceData(message string, code ExitCode, perfDataList []perfdata.PerformanceData) ExitCode {
-	if len(perfDataList) == 0 {
-		return Print(message, code)
+// Render returns the output as printed by PrintWithLongOutput
+func (p *Printer) Render(message string, longOutput string, code ExitCode,
+	perfDataList []perfdata.PerformanceData) string {
+	if p.Sanitise {
+		message = SanitiseSummary(message)
+		longOutput = SanitiseLongOutput(longOutput)
 	}
-	perfData := ""
+	summary := fmt.Sprintf("%s - %s", code.String(), message)
+
+	var perfData []string
 	for _, pd := range perfDataList {
-		if perfData == "" {
-			perfData = pd.String()
-		} else {
-			perfData += " " + pd.String()
+		perfData = append(perfData, pd.String())
+	}
+
+	var lines []string
+	if longOutput != "" {
+		lines = strings.Split(strings.TrimSuffix(longOutput, "\n"), "\n")
+	}
+
+	output := composeOutput(summary, perfData, lines, "")
+	if p.MaxLength <= 0 || len(output) <= p.MaxLength {
+		return output
+	}
+
+	// drop lines, then performance data, from the end until the output with the marker fits,
+	// the length is updated per entry instead of composing the output on each step
+	marker := 0
+	if p.TruncationMarker != "" {
+		marker = len(p.TruncationMarker) + 1
+	}
+	length := len(output) + marker
+	for len(lines) > 0 && length > p.MaxLength {
+		length -= len(lines[len(lines)-1]) + 1
+		lines = lines[:len(lines)-1]
+	}
+	for len(perfData) > 0 && length > p.MaxLength {
+		length -= len(perfData[len(perfData)-1]) + 1
+		perfData = perfData[:len(perfData)-1]
+		if len(perfData) == 0 {
+			// the " | " separator is gone with the last entry
+			length -= 2
 		}
 	}
-	fmt.Printf("%s - %s | %s\n", code.String(), message, perfData)
-	return code
+
+	return composeOutput(summary, perfData, lines, p.TruncationMarker)
+}
+
+func composeOutput(summary string, perfData []string, lines []string, marker string) string {
+	var b strings.Builder
+	b.WriteString(summary)
+	if len(perfData) > 0 {
+		b.WriteString(" | ")
+		b.WriteString(strings.Join(perfData, " "))
+	}
+	b.WriteString("\n")
+	for _, l := range lines {
+		b.WriteString(l)
+		b.WriteString("\n")
+	}
+	if marker != "" {
+		b.WriteString(marker)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func (p *Printer) PrintUnknown(message string) ExitCode {
+	return p.Print(message, ExitUnknown)
+}
+
+func (p *Printer) PrintWarning(message string) ExitCode {
+	return p.Print(message, ExitWarning)
+}
+
+func (p *Printer) PrintWarningWithPerformanceData(message string, perfData *perfdata.PerformanceData) ExitCode {
+	if perfData == nil {
+		return p.PrintWarning(message)
+	}
+	return p.PrintWithPerformanceData(message, ExitWarning, []perfdata.PerformanceData{*perfData})
+}
+
+func (p *Printer) PrintCritical(message string) ExitCode {
+	return p.Print(message, ExitCritical)
+}
+
+func (p *Printer) PrintCriticalWithPerformanceData(message string, perfData *perfdata.PerformanceData) ExitCode {
+	if perfData == nil {
+		return p.PrintCritical(message)
+	}
+	return p.PrintWithPerformanceData(message, ExitCritical, []perfdata.PerformanceData{*perfData})
+}
+
+func (p *Printer) PrintOk(message string) ExitCode {
+	return p.Print(message, ExitOk)
+}
+
+func (p *Printer) PrintOkWithPerformanceData(message string, perfData *perfdata.PerformanceData) ExitCode {
+	if perfData == nil {
+		return p.PrintOk(message)
+	}
+	return p.PrintWithPerformanceData(message, ExitOk, []perfdata.PerformanceData{*perfData})
+}
+
+/*
+ * Print functions using the DefaultPrinter
+ */
+
+func Print(message string, code ExitCode) ExitCode {
+	return DefaultPrinter.Print(message, code)
+}
+
+func PrintWithPerformanceData(message string, code ExitCode, perfDataList []perfdata.PerformanceData) ExitCode {
+	return DefaultPrinter.PrintWithPerformanceData(message, code, perfDataList)
+}
+
+func PrintWithLongOutput(message string, longOutput string, code ExitCode, perfDataList []perfdata.PerformanceData) ExitCode {
+	return DefaultPrinter.PrintWithLongOutput(message, longOutput, code, perfDataList)
 }
 
 func PrintUnknown(message string) ExitCode {
-	return Print(message, ExitUnknown)
+	return DefaultPrinter.PrintUnknown(message)
 }
 
 func PrintWarning(message string) ExitCode {
-	return Print(message, ExitWarning)
+	return DefaultPrinter.PrintWarning(message)
 }
 
 func PrintWarningWithPerformanceData(message string, perfData *perfdata.PerformanceData) ExitCode {
-	if perfData == nil {
-		return PrintWarning(message)
-	}
-	return PrintWithPerformanceData(message, ExitWarning, []perfdata.PerformanceData{*perfData})
+	return DefaultPrinter.PrintWarningWithPerformanceData(message, perfData)
 }
 
 func PrintCritical(message string) ExitCode {
-	return Print(message, ExitCritical)
+	return DefaultPrinter.PrintCritical(message)
 }
 
 func PrintCriticalWithPerformanceData(message string, perfData *perfdata.PerformanceData) ExitCode {
-	if perfData == nil {
-		return PrintCritical(message)
-	}
-	return PrintWithPerformanceData(message, ExitCritical, []perfdata.PerformanceData{*perfData})
+	return DefaultPrinter.PrintCriticalWithPerformanceData(message, perfData)
 }
 
 func PrintOk(message string) ExitCode {
-	return Print(message, ExitOk)
+	return DefaultPrinter.PrintOk(message)
 }
 
 func PrintOkWithPerformanceData(message string, perfData *perfdata.PerformanceData) ExitCode {
-	if perfData == nil {
-		return PrintOk(message)
-	}
-	return PrintWithPerformanceData(message, ExitOk, []perfdata.PerformanceData{*perfData})
+	return DefaultPrinter.PrintOkWithPerformanceData(message, perfData)
 }
@@ -0,0 +1,149 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func expectOutput(t *testing.T, buf *bytes.Buffer, want string) {
+	t.Helper()
+	if buf.String() != want {
+		t.Errorf("Output was incorrect, got: %q, want: %q.", buf.String(), want)
+	}
+	buf.Reset()
+}
+
+func TestPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	p := CreatePrinter(&buf)
+
+	if code := p.PrintOk("all fine"); code != ExitOk {
+		t.Errorf("Expecting %s, got %s", ExitOk, code)
+	}
+	expectOutput(t, &buf, "OK - all fine\n")
+
+	p.PrintWarning("slow")
+	expectOutput(t, &buf, "WARNING - slow\n")
+
+	p.PrintCritical("down")
+	expectOutput(t, &buf, "CRITICAL - down\n")
+
+	if code := p.PrintUnknown("no data"); code != ExitUnknown {
+		t.Errorf("Expecting %s, got %s", ExitUnknown, code)
+	}
+	expectOutput(t, &buf, "UNKNOWN - no data\n")
+}
+
+func TestPrinterWithPerformanceData(t *testing.T) {
+	var buf bytes.Buffer
+	p := CreatePrinter(&buf)
+
+	pd := perfdata.CreatePerformanceData("load", 2, "")
+	p.PrintWarningWithPerformanceData("load", pd)
+	expectOutput(t, &buf, "WARNING - load | 'load'=2;;;;\n")
+
+	p.PrintCriticalWithPerformanceData("load", nil)
+	expectOutput(t, &buf, "CRITICAL - load\n")
+
+	p.PrintOkWithPerformanceData("load", pd)
+	expectOutput(t, &buf, "OK - load | 'load'=2;;;;\n")
+
+	list := []perfdata.PerformanceData{*pd, *perfdata.CreatePerformanceData("time", 1.5, "s")}
+	p.PrintWithPerformanceData("two", ExitOk, list)
+	expectOutput(t, &buf, "OK - two | 'load'=2;;;; 'time'=1.5s;;;;\n")
+
+	p.PrintWithPerformanceData("none", ExitOk, nil)
+	expectOutput(t, &buf, "OK - none\n")
+}
+
+func TestDefaultPrinter(t *testing.T) {
+	var buf bytes.Buffer
+	saved := DefaultPrinter
+	DefaultPrinter = CreatePrinter(&buf)
+	defer func() { DefaultPrinter = saved }()
+
+	PrintCritical("down")
+	expectOutput(t, &buf, "CRITICAL - down\n")
+}
+
+func TestPrintWithLongOutput(t *testing.T) {
+	var buf bytes.Buffer
+	p := CreatePrinter(&buf)
+
+	pd := perfdata.CreatePerformanceData("load", 2, "")
+	p.PrintWithLongOutput("summary", "line 1\nline 2\n", ExitWarning, []perfdata.PerformanceData{*pd})
+	expectOutput(t, &buf, "WARNING - summary | 'load'=2;;;;\nline 1\nline 2\n")
+
+	p.PrintWithLongOutput("summary", "line 1", ExitOk, nil)
+	expectOutput(t, &buf, "OK - summary\nline 1\n")
+}
+
+func TestTruncation(t *testing.T) {
+	p := CreatePrinter(nil)
+	list := []perfdata.PerformanceData{
+		*perfdata.CreatePerformanceData("a", 1, ""),
+		*perfdata.CreatePerformanceData("b", 2, ""),
+	}
+
+	full := "OK - summary | 'a'=1;;;; 'b'=2;;;;\nline 1\nline 2\n"
+	expectRender(t, p, 0, list, full)
+	expectRender(t, p, len(full), list, full)
+
+	// long output is truncated first
+	expectRender(t, p, len(full)-1, list, "OK - summary | 'a'=1;;;;\n[output truncated]\n")
+	p.TruncationMarker = "..."
+	expectRender(t, p, len(full)-1, list, "OK - summary | 'a'=1;;;; 'b'=2;;;;\nline 1\n...\n")
+
+	// then whole performance data entries are dropped
+	expectRender(t, p, 30, list, "OK - summary | 'a'=1;;;;\n...\n")
+	expectRender(t, p, 20, list, "OK - summary\n...\n")
+
+	// the summary is never truncated
+	expectRender(t, p, 5, list, "OK - summary\n...\n")
+}
+
+func TestTruncationLargeOutput(t *testing.T) {
+	p := CreatePrinter(nil)
+	p.MaxLength = MaxLengthNRPEv3
+	longOutput := strings.Repeat("a line of the long output\n", 100000)
+
+	output := p.Render("summary", longOutput, ExitOk, nil)
+	if len(output) > MaxLengthNRPEv3 || len(output) < MaxLengthNRPEv3-30 {
+		t.Errorf("Render exceeds or wastes the limit, got %d bytes", len(output))
+	}
+	if !strings.HasSuffix(output, "\n"+DefaultTruncationMarker+"\n") ||
+		!strings.HasPrefix(longOutput, strings.TrimPrefix(strings.TrimSuffix(output, DefaultTruncationMarker+"\n"), "OK - summary\n")) {
+		t.Errorf("Unexpected truncated output %q", output[len(output)-100:])
+	}
+}
+
+func expectRender(t *testing.T, p *Printer, maxLength int, list []perfdata.PerformanceData, want string) {
+	t.Helper()
+	p.MaxLength = maxLength
+	output := p.Render("summary", "line 1\nline 2", ExitOk, list)
+	if output != want {
+		t.Errorf("Render with limit %d was incorrect, got: %q, want: %q.", maxLength, output, want)
+	}
+	if maxLength > 0 && len(output) > maxLength && output != "OK - summary\n"+p.TruncationMarker+"\n" {
+		t.Errorf("Render exceeds limit %d: %q", maxLength, output)
+	}
+}
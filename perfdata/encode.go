@@ -0,0 +1,310 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source identifies the check run of encoded performance data
+type Source struct {
+	Host  string
+	Check string
+	Time  time.Time
+}
+
+/*
+ * Unit normalisation
+ */
+
+// Base units of normalised values
+const (
+	UnitNone    = ""
+	UnitSeconds = "seconds"
+	UnitBytes   = "bytes"
+	UnitPercent = "percent"
+	UnitCounter = "total"
+)
+
+var unitFactors = map[string]struct {
+	unit   string
+	factor float64
+}{
+	"":    {UnitNone, 1},
+	"s":   {UnitSeconds, 1},
+	"ms":  {UnitSeconds, 1e-3},
+	"us":  {UnitSeconds, 1e-6},
+	"ns":  {UnitSeconds, 1e-9},
+	"%":   {UnitPercent, 1},
+	"c":   {UnitCounter, 1},
+	"B":   {UnitBytes, 1},
+	"KB":  {UnitBytes, 1e3},
+	"MB":  {UnitBytes, 1e6},
+	"GB":  {UnitBytes, 1e9},
+	"TB":  {UnitBytes, 1e12},
+	"PB":  {UnitBytes, 1e15},
+	"KiB": {UnitBytes, 1 << 10},
+	"MiB": {UnitBytes, 1 << 20},
+	"GiB": {UnitBytes, 1 << 30},
+	"TiB": {UnitBytes, 1 << 40},
+	"PiB": {UnitBytes, 1 << 50},
+}
+
+// NormaliseUnit converts a value of the unit of measurement into its base unit,
+// e.g. 5ms into 0.005 seconds. Unknown units are kept unchanged.
+func NormaliseUnit(value float64, UOM string) (float64, string) {
+	if f, ok := unitFactors[UOM]; ok {
+		return value * f.factor, f.unit
+	}
+	return value, UOM
+}
+
+// normalisedNumber returns a plain number of minimum, maximum or threshold in the base unit
+func normalisedNumber(number string, UOM string) (float64, bool) {
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	value, _ = NormaliseUnit(value, UOM)
+	return value, true
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// sanitise replaces all characters not accepted by valid with "_"
+func sanitise(s string, valid func(r rune) bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		if valid(r) {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+func isAlphaNumeric(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_'
+}
+
+/*
+ * Prometheus text exposition format
+ */
+
+// EncodePrometheus returns the performance data in the Prometheus text exposition format.
+// Each metric is named "icinga_perfdata_<metric>_<unit>" with the labels host, check and, for
+// labels like "db1::connections", instance. Percentages are exported as ratio, values with the
+// unit c as counter and all others as gauge.
+func EncodePrometheus(list []PerformanceData, source Source) string {
+	type sample struct {
+		labels string
+		value  float64
+	}
+	var names []string
+	types := make(map[string]string)
+	samples := make(map[string][]sample)
+
+	for _, pd := range list {
+		value, unit := NormaliseUnit(pd.Value, pd.UOM)
+		metricType := "gauge"
+		switch unit {
+		case UnitPercent:
+			value /= 100
+			unit = "ratio"
+		case UnitCounter:
+			metricType = "counter"
+		}
+
+		// a namespace of its own keeps the names apart from other metrics like icinga_check_state
+		name := "icinga_perfdata_" + PrometheusName(Metric(pd.Label))
+		if unit != "" && !strings.HasSuffix(name, "_"+unit) {
+			name += "_" + PrometheusName(unit)
+		}
+
+		labels := []string{
+			"host=" + PrometheusLabelValue(source.Host),
+			"check=" + PrometheusLabelValue(source.Check),
+		}
+		if parts := SplitLabel(pd.Label); len(parts) > 1 {
+			labels = append(labels, "instance="+PrometheusLabelValue(JoinLabel(parts[:len(parts)-1]...)))
+		}
+
+		if _, ok := types[name]; !ok {
+			names = append(names, name)
+			types[name] = metricType
+		}
+		samples[name] = append(samples[name], sample{labels: strings.Join(labels, ","), value: value})
+	}
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString("# TYPE " + name + " " + types[name] + "\n")
+		for _, s := range samples[name] {
+			b.WriteString(name + "{" + s.labels + "} " + prometheusValue(s.value) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// PrometheusName sanitises a metric name, only letters, digits and "_" are kept
+// and a leading digit is prefixed with "_"
+func PrometheusName(name string) string {
+	name = sanitise(name, isAlphaNumeric)
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "_" + name
+	}
+	return name
+}
+
+// PrometheusLabelValue returns the quoted and escaped label value
+func PrometheusLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+func prometheusValue(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return formatFloat(value)
+}
+
+/*
+ * InfluxDB line protocol
+ */
+
+// EncodeInflux returns the performance data in the InfluxDB line protocol, one line per
+// performance data with the check as measurement, the tags host, metric and unit and the
+// fields value, warn, crit, min and max in the base unit. Unavailable values are skipped.
+func EncodeInflux(list []PerformanceData, source Source) string {
+	measurement := source.Check
+	if measurement == "" {
+		measurement = "icinga"
+	}
+	timestamp := ""
+	if !source.Time.IsZero() {
+		timestamp = " " + strconv.FormatInt(source.Time.UnixNano(), 10)
+	}
+
+	var b strings.Builder
+	for _, pd := range list {
+		value, unit := NormaliseUnit(pd.Value, pd.UOM)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		tags := map[string]string{"host": source.Host, "metric": pd.Label, "unit": unit}
+		var keys []string
+		for k, v := range tags {
+			if v != "" {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		b.WriteString(influxEscape(measurement, ", "))
+		for _, k := range keys {
+			b.WriteString("," + k + "=" + influxEscape(tags[k], ",= "))
+		}
+
+		b.WriteString(" value=" + formatFloat(value))
+		for _, f := range []struct{ key, number string }{
+			{"warn", pd.Warning}, {"crit", pd.Critical}, {"min", pd.Minimum}, {"max", pd.Maximum},
+		} {
+			if v, ok := normalisedNumber(f.number, pd.UOM); ok {
+				b.WriteString("," + f.key + "=" + formatFloat(v))
+			}
+		}
+		b.WriteString(timestamp + "\n")
+	}
+	return b.String()
+}
+
+// influxEscape escapes the special characters with a backslash, line breaks are replaced
+func influxEscape(s string, special string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", " ")
+	for _, c := range special {
+		s = strings.ReplaceAll(s, string(c), `\`+string(c))
+	}
+	return s
+}
+
+/*
+ * Graphite plaintext protocol
+ */
+
+// EncodeGraphite returns the performance data in the Graphite plaintext protocol with paths
+// like "icinga.<host>.<check>.perfdata.<label>.value" and the numeric warn, crit, min and max
+// in the base unit. The parts of labels like "db1::connections" become separate path nodes.
+// Unavailable values are skipped.
+func EncodeGraphite(list []PerformanceData, source Source) string {
+	t := source.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	timestamp := " " + strconv.FormatInt(t.Unix(), 10) + "\n"
+
+	prefix := "icinga." + GraphiteNode(source.Host) + "." + GraphiteNode(source.Check) + ".perfdata."
+
+	var b strings.Builder
+	for _, pd := range list {
+		value, _ := NormaliseUnit(pd.Value, pd.UOM)
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			continue
+		}
+
+		var nodes []string
+		for _, p := range SplitLabel(pd.Label) {
+			nodes = append(nodes, GraphiteNode(p))
+		}
+		path := prefix + strings.Join(nodes, ".")
+
+		b.WriteString(path + ".value " + formatFloat(value) + timestamp)
+		for _, f := range []struct{ key, number string }{
+			{"warn", pd.Warning}, {"crit", pd.Critical}, {"min", pd.Minimum}, {"max", pd.Maximum},
+		} {
+			if v, ok := normalisedNumber(f.number, pd.UOM); ok {
+				b.WriteString(path + "." + f.key + " " + formatFloat(v) + timestamp)
+			}
+		}
+	}
+	return b.String()
+}
+
+// GraphiteNode sanitises a node of a Graphite path, only letters, digits, "_" and "-" are kept
+func GraphiteNode(node string) string {
+	node = sanitise(node, func(r rune) bool { return isAlphaNumeric(r) || r == '-' })
+	if node == "" {
+		return "_"
+	}
+	return node
+}
@@ -0,0 +1,115 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"testing"
+	"time"
+)
+
+func encodeTestData() []PerformanceData {
+	rt := CreatePerformanceData("response time", 250, "ms")
+	rt.SetWarning("500")
+	rt.SetCritical("@1000:")
+	return []PerformanceData{
+		*rt,
+		*CreatePerformanceData("db1::connections", 8, ""),
+		*CreatePerformanceData("db2::connections", 3, ""),
+		*CreatePerformanceData("usage", 75, "%"),
+		*CreatePerformanceData("size", 1.5, "KiB"),
+		*CreatePerformanceData("errors", 12, "c"),
+		*CreateUnknownPerformanceData("missing", ""),
+	}
+}
+
+var encodeTestSource = Source{Host: "web 1", Check: "http,\"x\"", Time: time.Unix(1700000000, 0)}
+
+func TestNormaliseUnit(t *testing.T) {
+	normaliseUnit(t, 5, "ms", 0.005, UnitSeconds)
+	normaliseUnit(t, 2, "us", 0.000002, UnitSeconds)
+	normaliseUnit(t, 3, "MB", 3e6, UnitBytes)
+	normaliseUnit(t, 1, "GiB", 1073741824, UnitBytes)
+	normaliseUnit(t, 42, "%", 42, UnitPercent)
+	normaliseUnit(t, 7, "c", 7, UnitCounter)
+	normaliseUnit(t, 7, "", 7, UnitNone)
+	normaliseUnit(t, 7, "rpm", 7, "rpm")
+}
+
+func normaliseUnit(t *testing.T, value float64, uom string, want float64, wantUnit string) {
+	t.Helper()
+	v, unit := NormaliseUnit(value, uom)
+	if v != want || unit != wantUnit {
+		t.Errorf("NormaliseUnit of %g%s was incorrect, got: %g %s, want: %g %s.", value, uom, v, unit, want, wantUnit)
+	}
+}
+
+func TestEncodePrometheus(t *testing.T) {
+	want := `# TYPE icinga_perfdata_response_time_seconds gauge
+icinga_perfdata_response_time_seconds{host="web 1",check="http,\"x\""} 0.25
+# TYPE icinga_perfdata_connections gauge
+icinga_perfdata_connections{host="web 1",check="http,\"x\"",instance="db1"} 8
+icinga_perfdata_connections{host="web 1",check="http,\"x\"",instance="db2"} 3
+# TYPE icinga_perfdata_usage_ratio gauge
+icinga_perfdata_usage_ratio{host="web 1",check="http,\"x\""} 0.75
+# TYPE icinga_perfdata_size_bytes gauge
+icinga_perfdata_size_bytes{host="web 1",check="http,\"x\""} 1536
+# TYPE icinga_perfdata_errors_total counter
+icinga_perfdata_errors_total{host="web 1",check="http,\"x\""} 12
+# TYPE icinga_perfdata_missing gauge
+icinga_perfdata_missing{host="web 1",check="http,\"x\""} NaN
+`
+	if got := EncodePrometheus(encodeTestData(), encodeTestSource); got != want {
+		t.Errorf("EncodePrometheus was incorrect, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPrometheusName(t *testing.T) {
+	for name, want := range map[string]string{"load": "load", "disk /var": "disk__var", "5min": "_5min", "": "_"} {
+		if got := PrometheusName(name); got != want {
+			t.Errorf("PrometheusName of %q was incorrect, got: %s, want: %s.", name, got, want)
+		}
+	}
+}
+
+func TestEncodeInflux(t *testing.T) {
+	want := `http\,"x",host=web\ 1,metric=response\ time,unit=seconds value=0.25,warn=0.5 1700000000000000000
+http\,"x",host=web\ 1,metric=db1::connections value=8 1700000000000000000
+http\,"x",host=web\ 1,metric=db2::connections value=3 1700000000000000000
+http\,"x",host=web\ 1,metric=usage,unit=percent value=75,min=0,max=100 1700000000000000000
+http\,"x",host=web\ 1,metric=size,unit=bytes value=1536 1700000000000000000
+http\,"x",host=web\ 1,metric=errors,unit=total value=12 1700000000000000000
+`
+	if got := EncodeInflux(encodeTestData(), encodeTestSource); got != want {
+		t.Errorf("EncodeInflux was incorrect, got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEncodeGraphite(t *testing.T) {
+	want := `icinga.web_1.http__x_.perfdata.response_time.value 0.25 1700000000
+icinga.web_1.http__x_.perfdata.response_time.warn 0.5 1700000000
+icinga.web_1.http__x_.perfdata.db1.connections.value 8 1700000000
+icinga.web_1.http__x_.perfdata.db2.connections.value 3 1700000000
+icinga.web_1.http__x_.perfdata.usage.value 75 1700000000
+icinga.web_1.http__x_.perfdata.usage.min 0 1700000000
+icinga.web_1.http__x_.perfdata.usage.max 100 1700000000
+icinga.web_1.http__x_.perfdata.size.value 1536 1700000000
+icinga.web_1.http__x_.perfdata.errors.value 12 1700000000
+`
+	if got := EncodeGraphite(encodeTestData(), encodeTestSource); got != want {
+		t.Errorf("EncodeGraphite was incorrect, got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,87 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"math"
+	"strconv"
+)
+
+// Formatting mode of numbers
+type FormatMode int
+
+const (
+	// FormatShortest uses the shortest representation which parses back into the same number
+	FormatShortest FormatMode = iota
+	// FormatFixed uses a fixed number of decimals
+	FormatFixed
+	// FormatSignificant rounds to a number of significant digits
+	FormatSignificant
+)
+
+// Format of the numbers of performance data, the zero value is FormatShortest.
+// Numbers are always written without exponent as required by the plugin API.
+type Format struct {
+	Mode   FormatMode
+	Digits int
+}
+
+// FixedFormat returns a format with the given number of decimals
+func FixedFormat(decimals int) Format {
+	return Format{Mode: FormatFixed, Digits: decimals}
+}
+
+// SignificantFormat returns a format rounding to the given number of significant digits
+func SignificantFormat(digits int) Format {
+	return Format{Mode: FormatSignificant, Digits: digits}
+}
+
+// FormatNumber formats a number, NaN and ±Inf cannot be represented and result in an empty string
+func (f Format) FormatNumber(value float64) string {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return ""
+	}
+
+	var s string
+	switch f.Mode {
+	case FormatFixed:
+		s = strconv.FormatFloat(value, 'f', f.Digits, 64)
+	case FormatSignificant:
+		digits := f.Digits
+		if digits < 1 {
+			digits = 1
+		}
+		rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', digits, 64), 64)
+		s = strconv.FormatFloat(rounded, 'f', -1, 64)
+	default:
+		s = strconv.FormatFloat(value, 'f', -1, 64)
+	}
+
+	if s[0] == '-' && isZero(s[1:]) {
+		return s[1:]
+	}
+	return s
+}
+
+func isZero(s string) bool {
+	for _, c := range s {
+		if c != '0' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
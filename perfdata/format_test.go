@@ -0,0 +1,89 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatShortest(t *testing.T) {
+	var f Format
+	a, b := 0.1, 0.2
+	formatNumber(t, f, 123.43, "123.43")
+	formatNumber(t, f, 123, "123")
+	formatNumber(t, f, 0.0000012, "0.0000012")
+	formatNumber(t, f, -1.5, "-1.5")
+	formatNumber(t, f, math.Copysign(0, -1), "0")
+	formatNumber(t, f, 1e20, "100000000000000000000")
+	formatNumber(t, f, -9.3e18, "-9300000000000000000")
+	formatNumber(t, f, a+b, "0.30000000000000004")
+	formatNumber(t, f, math.NaN(), "")
+	formatNumber(t, f, math.Inf(1), "")
+	formatNumber(t, f, math.Inf(-1), "")
+}
+
+func TestFormatFixed(t *testing.T) {
+	f := FixedFormat(2)
+	formatNumber(t, f, 123.456, "123.46")
+	formatNumber(t, f, 123, "123.00")
+	formatNumber(t, f, -0.001, "0.00")
+	formatNumber(t, FixedFormat(0), 2.5, "2")
+	formatNumber(t, FixedFormat(6), 123.43, "123.430000")
+}
+
+func TestFormatSignificant(t *testing.T) {
+	f := SignificantFormat(3)
+	formatNumber(t, f, 123.456, "123")
+	formatNumber(t, f, 0.0000012345, "0.00000123")
+	formatNumber(t, f, 98765, "98800")
+	formatNumber(t, f, 1e20, "100000000000000000000")
+	formatNumber(t, SignificantFormat(0), 0.56, "0.6")
+}
+
+func formatNumber(t *testing.T, f Format, value float64, want string) {
+	t.Helper()
+	if s := f.FormatNumber(value); s != want {
+		t.Errorf("FormatNumber of %g was incorrect, got: %s, want: %s.", value, s, want)
+	}
+}
+
+func TestPerformanceDataFormat(t *testing.T) {
+	pd := CreatePerformanceData("testing", 0.0000012, "s")
+	pd.SetWarningValue(0.5)
+	pd.SetCriticalValue(math.Inf(1))
+	pd.SetMinimumValue(0)
+	pd.SetMaximumValue(1e20)
+
+	want := "'testing'=0.0000012s;0.5;;0;100000000000000000000"
+	if pd.String() != want {
+		t.Errorf("String was incorrect, got: %s, want: %s.", pd.String(), want)
+	}
+
+	pd.SetFormat(FixedFormat(3))
+	pd.SetWarningValue(0.5)
+	want = "'testing'=0.000s;0.500;;0;100000000000000000000"
+	if pd.String() != want {
+		t.Errorf("String was incorrect, got: %s, want: %s.", pd.String(), want)
+	}
+
+	pd = CreatePerformanceData("testing", math.Inf(-1), "s")
+	want = "'testing'=U;;;;"
+	if pd.String() != want {
+		t.Errorf("String was incorrect, got: %s, want: %s.", pd.String(), want)
+	}
+}
@@ -0,0 +1,111 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// LabelSeparator separates the parts of a label as in Icinga 2's check_multi
+// convention, e.g. "db1::connections"
+const LabelSeparator = "::"
+
+// JoinLabel joins the non-empty parts into a label separated by "::", the parts
+// are escaped so that they do not contain "::" or "=" themselves
+func JoinLabel(parts ...string) string {
+	var escaped []string
+	for _, p := range parts {
+		p = escapeLabelPart(p)
+		if p != "" {
+			escaped = append(escaped, p)
+		}
+	}
+	return strings.Join(escaped, LabelSeparator)
+}
+
+// SplitLabel splits a label into its parts separated by "::"
+func SplitLabel(label string) []string {
+	return strings.Split(label, LabelSeparator)
+}
+
+// Metric returns the last part of a label, e.g. "connections" of "db1::connections"
+func Metric(label string) string {
+	parts := SplitLabel(label)
+	return parts[len(parts)-1]
+}
+
+func escapeLabelPart(part string) string {
+	part = strings.TrimSpace(part)
+	part = strings.ReplaceAll(part, "=", "_")
+	return strings.ReplaceAll(part, LabelSeparator, "_")
+}
+
+// LabelBuilder builds unique labels with a common prefix for one output
+type LabelBuilder struct {
+	Prefix string
+	// AutoSuffix appends "_2", "_3", ... to duplicate labels instead of returning an error
+	AutoSuffix bool
+	used       map[string]bool
+}
+
+// CreateLabelBuilder creates and returns a new LabelBuilder for labels with the given prefix
+func CreateLabelBuilder(prefix string) *LabelBuilder {
+	lb := new(LabelBuilder)
+	lb.Prefix = prefix
+	lb.used = make(map[string]bool)
+
+	return lb
+}
+
+// Label returns the unique label for the metric of the instance, e.g. "prefix::db1::connections".
+// The instance may be empty for metrics without instance.
+func (lb *LabelBuilder) Label(instance string, metric string) (string, error) {
+	if escapeLabelPart(metric) == "" {
+		return "", errors.New("empty metric")
+	}
+	if lb.used == nil {
+		lb.used = make(map[string]bool)
+	}
+
+	label := JoinLabel(lb.Prefix, instance, metric)
+	if !lb.used[label] {
+		lb.used[label] = true
+		return label, nil
+	}
+
+	if !lb.AutoSuffix {
+		return label, fmt.Errorf("duplicate label %s", label)
+	}
+	for i := 2; ; i++ {
+		suffixed := fmt.Sprintf("%s_%d", label, i)
+		if !lb.used[suffixed] {
+			lb.used[suffixed] = true
+			return suffixed, nil
+		}
+	}
+}
+
+// Create creates a PerformanceData object with the label of the metric of the instance
+func (lb *LabelBuilder) Create(instance string, metric string, Value float64, UOM string) (*PerformanceData, error) {
+	label, err := lb.Label(instance, metric)
+	if err != nil {
+		return nil, err
+	}
+	return CreatePerformanceData(label, Value, UOM), nil
+}
@@ -0,0 +1,97 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJoinLabel(t *testing.T) {
+	joinLabel(t, "db1::connections", "db1", "connections")
+	joinLabel(t, "pg::db1::connections", "pg", "db1", "connections")
+	joinLabel(t, "connections", "", "connections")
+	joinLabel(t, "a_b::c_d", " a::b ", "c=d")
+}
+
+func joinLabel(t *testing.T, want string, parts ...string) {
+	if label := JoinLabel(parts...); label != want {
+		t.Errorf("JoinLabel was incorrect, got: %s, want: %s.", label, want)
+	}
+}
+
+func TestSplitLabel(t *testing.T) {
+	if parts := strings.Join(SplitLabel("pg::db1::connections"), ","); parts != "pg,db1,connections" {
+		t.Errorf("SplitLabel was incorrect, got: %s", parts)
+	}
+	if metric := Metric("pg::db1::connections"); metric != "connections" {
+		t.Errorf("Metric was incorrect, got: %s", metric)
+	}
+	if metric := Metric("connections"); metric != "connections" {
+		t.Errorf("Metric was incorrect, got: %s", metric)
+	}
+}
+
+func TestLabelBuilder(t *testing.T) {
+	lb := CreateLabelBuilder("pg")
+
+	label, err := lb.Label("db1", "connections")
+	if err != nil || label != "pg::db1::connections" {
+		t.Errorf("Label was incorrect, got: %s, %v", label, err)
+	}
+
+	label, err = lb.Label("db2", "connections")
+	if err != nil || label != "pg::db2::connections" {
+		t.Errorf("Label was incorrect, got: %s, %v", label, err)
+	}
+
+	_, err = lb.Label("db1", "connections")
+	if err == nil || err.Error() != "duplicate label pg::db1::connections" {
+		t.Errorf("Expecting duplicate label error, got: %v", err)
+	}
+
+	_, err = lb.Label("db1", " ")
+	if err == nil || err.Error() != "empty metric" {
+		t.Errorf("Expecting empty metric error, got: %v", err)
+	}
+}
+
+func TestLabelBuilderAutoSuffix(t *testing.T) {
+	lb := CreateLabelBuilder("")
+	lb.AutoSuffix = true
+
+	for _, want := range []string{"db::size", "db::size_2", "db::size_3"} {
+		label, err := lb.Label("db", "size")
+		if err != nil || label != want {
+			t.Errorf("Label was incorrect, got: %s, want: %s, %v", label, want, err)
+		}
+	}
+}
+
+func TestLabelBuilderCreate(t *testing.T) {
+	lb := CreateLabelBuilder("")
+
+	pd, err := lb.Create("it's", "size", 12, "B")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	want := "'it''s::size'=12B;;;;"
+	if pd.String() != want {
+		t.Errorf("Create was incorrect, got: %s, want: %s.", pd.String(), want)
+	}
+}
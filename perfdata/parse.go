@@ -0,0 +1,127 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Parse parses the performance data of a plugin output like "'label'=value[UOM];warn;crit;min;max ...".
+// Labels may be quoted with single quotes, a quote inside is doubled. A value of U is parsed
+// as NaN. The thresholds, minimum and maximum are kept as strings.
+func Parse(s string) ([]PerformanceData, error) {
+	var list []PerformanceData
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return list, nil
+		}
+
+		pd, rest, err := parseEntry(s)
+		if err != nil {
+			return list, err
+		}
+		list = append(list, *pd)
+		s = rest
+	}
+}
+
+// parseEntry parses the first entry of s and returns it along with the remaining string
+func parseEntry(s string) (*PerformanceData, string, error) {
+	label, rest, err := parseLabel(s)
+	if err != nil {
+		return nil, "", err
+	}
+
+	end := strings.IndexAny(rest, " \t")
+	if end < 0 {
+		end = len(rest)
+	}
+	fields := strings.Split(rest[:end], ";")
+	if len(fields) > 5 {
+		return nil, "", fmt.Errorf("too many fields in performance data %s", label)
+	}
+
+	pd := new(PerformanceData)
+	pd.Label = label
+	if pd.Value, pd.UOM, err = parseValue(fields[0]); err != nil {
+		return nil, "", fmt.Errorf("invalid value of performance data %s: %s", label, err.Error())
+	}
+	for i, f := range []*string{&pd.Warning, &pd.Critical, &pd.Minimum, &pd.Maximum} {
+		if i+1 < len(fields) {
+			*f = fields[i+1]
+		}
+	}
+
+	return pd, rest[end:], nil
+}
+
+// parseLabel parses the label including the "=" and returns the remaining string
+func parseLabel(s string) (string, string, error) {
+	if s[0] != '\'' {
+		i := strings.IndexByte(s, '=')
+		if i <= 0 || strings.ContainsAny(s[:i], " \t") {
+			return "", "", fmt.Errorf("missing label of performance data %s", s)
+		}
+		return s[:i], s[i+1:], nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		if s[i] != '\'' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == '\'' {
+			b.WriteByte('\'')
+			i++
+			continue
+		}
+		if i+1 >= len(s) || s[i+1] != '=' || b.Len() == 0 {
+			return "", "", fmt.Errorf("invalid label of performance data %s", s)
+		}
+		return b.String(), s[i+2:], nil
+	}
+	return "", "", fmt.Errorf("unterminated label of performance data %s", s)
+}
+
+// parseValue parses a value with an optional unit of measurement
+func parseValue(s string) (float64, string, error) {
+	if s == "U" {
+		return math.NaN(), "", nil
+	}
+
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= '0' && r <= '9') && r != '.' && r != '-' && r != '+' && r != 'e' && r != 'E'
+	})
+	if i < 0 {
+		i = len(s)
+	}
+	// a unit starting with e like "events" is not an exponent
+	for i > 0 && (s[i-1] == 'e' || s[i-1] == 'E') {
+		i--
+	}
+
+	value, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid number %q", s)
+	}
+	return value, s[i:], nil
+}
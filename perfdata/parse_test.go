@@ -0,0 +1,84 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package perfdata
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	list, err := Parse("load1=0.5;1;2;0 'disk usage'=12.5GB;80;90;0;100  'it''s'=U time=5ms counter=10c events=3events")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if len(list) != 6 {
+		t.Fatalf("Expecting 6 performance data, got %d", len(list))
+	}
+
+	expectParsed(t, list[0], "load1", 0.5, "", "1", "2", "0", "")
+	expectParsed(t, list[1], "disk usage", 12.5, "GB", "80", "90", "0", "100")
+	expectParsed(t, list[3], "time", 5, "ms", "", "", "", "")
+	expectParsed(t, list[4], "counter", 10, "c", "", "", "", "")
+	expectParsed(t, list[5], "events", 3, "events", "", "", "", "")
+	if list[2].Label != "it's" || !math.IsNaN(list[2].Value) {
+		t.Errorf("Expecting unknown value of it's, got %s", list[2].String())
+	}
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	pd := CreatePerformanceData("db1::it's", -1.25e-3, "s")
+	pd.SetWarning("~:10")
+	pd.SetCritical("@5:6")
+
+	list, err := Parse(pd.String() + " " + CreateUnknownPerformanceData("x", "B").String())
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if len(list) != 2 || list[0].String() != pd.String() || list[1].String() != "'x'=U;;;;" {
+		t.Errorf("Unexpected round trip result %v", list)
+	}
+}
+
+func TestParseEmpty(t *testing.T) {
+	list, err := Parse("  ")
+	if err != nil || len(list) != 0 {
+		t.Errorf("Expecting no performance data, got %v %v", list, err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"load", "=1", "'load=1", "'load'1", "''=1", "load=abc", "load=1;2;3;4;5;6", "a b=1"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Expecting error for %q", s)
+		}
+	}
+
+	list, err := Parse("a=1 b=x")
+	if err == nil || len(list) != 1 {
+		t.Errorf("Expecting the valid entry along with the error, got %v %v", list, err)
+	}
+}
+
+func expectParsed(t *testing.T, pd PerformanceData, label string, value float64, UOM string,
+	warning string, critical string, minimum string, maximum string) {
+	t.Helper()
+	if pd.Label != label || pd.Value != value || pd.UOM != UOM || pd.Warning != warning ||
+		pd.Critical != critical || pd.Minimum != minimum || pd.Maximum != maximum {
+		t.Errorf("Unexpected performance data %s", pd.String())
+	}
+}
@@ -18,6 +18,8 @@ package perfdata
 
 import (
 	"fmt"
+	"math"
+	"strings"
 )
 
 // Performance data
@@ -29,18 +31,55 @@ type PerformanceData struct {
 	Critical string
 	Minimum  string
 	Maximum  string
+	Format   Format
 }
 
-// CreatePerformanceData creates and returns a new PerformanceData object
+// CreatePerformanceData creates and returns a new PerformanceData object,
+// percentages get a minimum of 0 and a maximum of 100
 func CreatePerformanceData(Label string, Value float64, UOM string) *PerformanceData {
 	pd := new(PerformanceData)
 	pd.Label = Label
 	pd.Value = Value
 	pd.UOM = UOM
 
+	if UOM == "%" {
+		pd.Minimum = "0"
+		pd.Maximum = "100"
+	}
+
 	return pd
 }
 
+// CreateUsage creates performance data for the used part of a total with a minimum
+// of 0 and the total as maximum and the derived percentage labeled "<label>_percent"
+func CreateUsage(Label string, used float64, total float64, UOM string) []PerformanceData {
+	pd := CreatePerformanceData(Label, used, UOM)
+	pd.SetMinimumValue(0)
+	pd.SetMaximumValue(total)
+
+	percent := math.NaN()
+	if total > 0 {
+		percent = used / total * 100
+	}
+
+	return []PerformanceData{*pd, *CreatePerformanceData(Label+"_percent", percent, "%")}
+}
+
+// CreateBytesUsage creates the performance data of CreateUsage for bytes
+func CreateBytesUsage(Label string, used float64, total float64) []PerformanceData {
+	return CreateUsage(Label, used, total, "B")
+}
+
+// CreateUnknownPerformanceData creates a PerformanceData object for an unavailable value
+func CreateUnknownPerformanceData(Label string, UOM string) *PerformanceData {
+	return CreatePerformanceData(Label, math.NaN(), UOM)
+}
+
+// IsUnknown returns true if the value is unavailable
+func (pd *PerformanceData) IsUnknown() bool {
+	return math.IsNaN(pd.Value)
+}
+
 // SetWarning sets the warning value of a PerformanceData object
 func (pd *PerformanceData) SetWarning(warning string) {
 	pd.Warning = warning
@@ -61,15 +100,41 @@ func (pd *PerformanceData) SetMaximum(maximum string) {
 	pd.Maximum = maximum
 }
 
-// String returns a PerformanceData object as formatted string without a separator
-func (pd *PerformanceData) String() string {
-	return fmt.Sprintf("'%s'=%s%s;%s;%s;%s;%s", pd.Label, getValueString(pd.Value),
-		pd.UOM, pd.Warning, pd.Critical, pd.Minimum, pd.Maximum)
+// SetFormat sets the format of the numbers of a PerformanceData object,
+// the number setters below use the format at the time they are called
+func (pd *PerformanceData) SetFormat(format Format) {
+	pd.Format = format
+}
+
+// SetWarningValue sets the warning value of a PerformanceData object from a number
+func (pd *PerformanceData) SetWarningValue(warning float64) {
+	pd.Warning = pd.Format.FormatNumber(warning)
+}
+
+// SetCriticalValue sets the critical value of a PerformanceData object from a number
+func (pd *PerformanceData) SetCriticalValue(critical float64) {
+	pd.Critical = pd.Format.FormatNumber(critical)
 }
 
-func getValueString(value float64) string {
-	if value == float64(int64(value)) {
-		return fmt.Sprintf("%d", int64(value))
+// SetMinimumValue sets the minimum value of a PerformanceData object from a number
+func (pd *PerformanceData) SetMinimumValue(minimum float64) {
+	pd.Minimum = pd.Format.FormatNumber(minimum)
+}
+
+// SetMaximumValue sets the maximum value of a PerformanceData object from a number
+func (pd *PerformanceData) SetMaximumValue(maximum float64) {
+	pd.Maximum = pd.Format.FormatNumber(maximum)
+}
+
+// String returns a PerformanceData object as formatted string without a separator,
+// an unavailable value or ±Inf is rendered as U without unit
+func (pd *PerformanceData) String() string {
+	label := strings.ReplaceAll(pd.Label, "'", "''")
+	value := pd.Format.FormatNumber(pd.Value)
+	if value == "" {
+		return fmt.Sprintf("'%s'=U;%s;%s;%s;%s", label,
+			pd.Warning, pd.Critical, pd.Minimum, pd.Maximum)
 	}
-	return fmt.Sprintf("%f", value)
+	return fmt.Sprintf("'%s'=%s%s;%s;%s;%s;%s", label, value,
+		pd.UOM, pd.Warning, pd.Critical, pd.Minimum, pd.Maximum)
 }
@@ -20,70 +20,70 @@ import "testing"
 
 func TestCreatePerformanceData(t *testing.T) {
 	pd := CreatePerformanceData("testing", 123.43, "")
-	want := "'testing'=123.430000;;;;"
+	want := "'testing'=123.43;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "s")
-	want = "'testing'=123.430000s;;;;"
+	want = "'testing'=123.43s;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "us")
-	want = "'testing'=123.430000us;;;;"
+	want = "'testing'=123.43us;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "ms")
-	want = "'testing'=123.430000ms;;;;"
+	want = "'testing'=123.43ms;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 99.43, "%")
-	want = "'testing'=99.430000%;;;;"
+	want = "'testing'=99.43%;;;0;100"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "B")
-	want = "'testing'=123.430000B;;;;"
+	want = "'testing'=123.43B;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "KB")
-	want = "'testing'=123.430000KB;;;;"
+	want = "'testing'=123.43KB;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "MB")
-	want = "'testing'=123.430000MB;;;;"
+	want = "'testing'=123.43MB;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "TB")
-	want = "'testing'=123.430000TB;;;;"
+	want = "'testing'=123.43TB;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 
 	pd = CreatePerformanceData("testing", 123.43, "c")
-	want = "'testing'=123.430000c;;;;"
+	want = "'testing'=123.43c;;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
@@ -95,7 +95,7 @@ func TestSetWarning(t *testing.T) {
 
 	pd.SetWarning("62")
 
-	want := "'testing'=123.430000;62;;;"
+	want := "'testing'=123.43;62;;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
@@ -107,7 +107,7 @@ func TestSetCritical(t *testing.T) {
 
 	pd.SetCritical("62")
 
-	want := "'testing'=123.430000;;62;;"
+	want := "'testing'=123.43;;62;;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
@@ -119,7 +119,7 @@ func TestSetMinimum(t *testing.T) {
 
 	pd.SetMinimum("62")
 
-	want := "'testing'=123.430000;;;62;"
+	want := "'testing'=123.43;;;62;"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
@@ -131,7 +131,7 @@ func TestSetMaximum(t *testing.T) {
 
 	pd.SetMaximum("62")
 
-	want := "'testing'=123.430000;;;;62"
+	want := "'testing'=123.43;;;;62"
 
 	if pd.String() != want {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
@@ -152,3 +152,44 @@ func TestString(t *testing.T) {
 		t.Errorf("CreatePerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
 	}
 }
+
+func TestUnknownValue(t *testing.T) {
+	pd := CreateUnknownPerformanceData("testing", "s")
+
+	if !pd.IsUnknown() {
+		t.Errorf("Expecting an unknown value")
+	}
+
+	pd.SetWarning("48")
+	pd.SetMaximum("875")
+
+	want := "'testing'=U;48;;;875"
+
+	if pd.String() != want {
+		t.Errorf("CreateUnknownPerformanceData was incorrect, got: %s, want: %s.", pd.String(), want)
+	}
+}
+
+func TestCreateUsage(t *testing.T) {
+	list := CreateBytesUsage("disk", 750, 1000)
+
+	if len(list) != 2 {
+		t.Fatalf("Expecting list of length %d, got %d", 2, len(list))
+	}
+
+	want := "'disk'=750B;;;0;1000"
+	if list[0].String() != want {
+		t.Errorf("CreateBytesUsage was incorrect, got: %s, want: %s.", list[0].String(), want)
+	}
+
+	want = "'disk_percent'=75%;;;0;100"
+	if list[1].String() != want {
+		t.Errorf("CreateBytesUsage was incorrect, got: %s, want: %s.", list[1].String(), want)
+	}
+
+	list = CreateUsage("inodes", 0, 0, "")
+	want = "'inodes_percent'=U;;;0;100"
+	if list[1].String() != want {
+		t.Errorf("CreateUsage was incorrect, got: %s, want: %s.", list[1].String(), want)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package runner
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/command"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// Check run by the runner
+type Check struct {
+	Name  string
+	Check icinga.CheckFunc
+	// Timeout of the check, 0 means the timeout of the runner
+	Timeout time.Duration
+}
+
+// NamedResult is the result of a check
+type NamedResult struct {
+	Name     string
+	Result   icinga.Result
+	Duration time.Duration
+}
+
+// Runner runs checks concurrently
+type Runner struct {
+	// Workers limits the number of checks running at the same time
+	Workers int
+	// Timeout of each check, 0 means no timeout
+	Timeout time.Duration
+	// Deadline of all checks relative to the start, 0 means no deadline. Checks not
+	// finished by then are UNKNOWN.
+	Deadline time.Duration
+	Checks   []Check
+}
+
+// CreateRunner creates and returns a new Runner with the worker limit and a timeout of 10 seconds
+func CreateRunner(workers int) *Runner {
+	r := new(Runner)
+	r.Workers = workers
+	r.Timeout = 10 * time.Second
+
+	return r
+}
+
+// Add adds a check function
+func (r *Runner) Add(name string, check icinga.CheckFunc) {
+	r.Checks = append(r.Checks, Check{Name: name, Check: check})
+}
+
+// AddCommand adds an external plugin, its own timeout applies in addition to the one of the runner
+func (r *Runner) AddCommand(name string, c *command.Command) {
+	r.Add(name, c.Check)
+}
+
+// Run runs all checks and returns their results in the order of the checks
+func (r *Runner) Run(ctx context.Context) []NamedResult {
+	if r.Deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Deadline)
+		defer cancel()
+	}
+
+	workers := r.Workers
+	if workers <= 0 || workers > len(r.Checks) {
+		workers = len(r.Checks)
+	}
+
+	results := make([]NamedResult, len(r.Checks))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = r.run(ctx, r.Checks[i])
+			}
+		}()
+	}
+
+	for i, c := range r.Checks {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			results[i] = NamedResult{Name: c.Name, Result: r.aborted(ctx, "not started")}
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+func (r *Runner) run(ctx context.Context, c Check) NamedResult {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = r.Timeout
+	}
+
+	checkCtx := ctx
+	cancel := func() {}
+	if timeout > 0 {
+		checkCtx, cancel = context.WithTimeout(ctx, timeout)
+	}
+	defer cancel()
+
+	start := time.Now()
+	result := icinga.RunCheck(checkCtx, c.Check, 0)
+	duration := time.Since(start)
+
+	switch {
+	case checkCtx.Err() == nil:
+	case ctx.Err() != nil:
+		result = r.aborted(ctx, "not finished")
+	default:
+		result = icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("check timed out after %s", timeout)}
+	}
+	return NamedResult{Name: c.Name, Result: result, Duration: duration}
+}
+
+func (r *Runner) aborted(ctx context.Context, what string) icinga.Result {
+	if ctx.Err() == context.DeadlineExceeded {
+		return icinga.Result{Code: icinga.ExitUnknown, Message: fmt.Sprintf("%s within the deadline of %s", what, r.Deadline)}
+	}
+	return icinga.Result{Code: icinga.ExitUnknown, Message: what + ", cancelled"}
+}
+
+// Merge merges the results into one. The exit code is combined by GetResultCode, the
+// message counts the states, the long output lists the results and the performance
+// data labels are prefixed with the names of the checks, e.g. "disk::/".
+func Merge(results []NamedResult) icinga.Result {
+	merged := icinga.Result{Code: icinga.ExitOk}
+	counts := make(map[icinga.ExitCode]int)
+	var lines []string
+
+	for _, nr := range results {
+		code := icinga.ExitCodeOf(int(nr.Result.Code))
+		merged.Code = merged.Code.GetResultCode(code)
+		counts[code]++
+
+		lines = append(lines, fmt.Sprintf("[%s] %s: %s", code.String(), nr.Name, nr.Result.Message))
+		if nr.Result.LongOutput != "" {
+			for _, l := range strings.Split(strings.TrimRight(nr.Result.LongOutput, "\n"), "\n") {
+				lines = append(lines, "  "+l)
+			}
+		}
+
+		prefix := perfdata.JoinLabel(nr.Name)
+		for _, pd := range nr.Result.PerfData {
+			if prefix != "" {
+				pd.Label = prefix + perfdata.LabelSeparator + pd.Label
+			}
+			merged.PerfData = append(merged.PerfData, pd)
+		}
+	}
+
+	var states []string
+	for _, code := range []icinga.ExitCode{icinga.ExitOk, icinga.ExitWarning, icinga.ExitCritical, icinga.ExitUnknown} {
+		if counts[code] > 0 {
+			states = append(states, fmt.Sprintf("%d %s", counts[code], code.String()))
+		}
+	}
+	merged.Message = fmt.Sprintf("%d checks", len(results))
+	if len(states) > 0 {
+		merged.Message += ": " + strings.Join(states, ", ")
+	}
+	merged.LongOutput = strings.Join(lines, "\n")
+
+	return merged
+}
@@ -0,0 +1,155 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package runner
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/command"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func sleeping(d time.Duration, code icinga.ExitCode) icinga.CheckFunc {
+	return func(ctx context.Context) icinga.Result {
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+		return icinga.Result{Code: code, Message: fmt.Sprintf("slept %s", d)}
+	}
+}
+
+func TestRunWorkers(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+
+	r := CreateRunner(3)
+	for i := 0; i < 12; i++ {
+		r.Add(fmt.Sprintf("check%d", i), func(ctx context.Context) icinga.Result {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(20 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return icinga.Result{Code: icinga.ExitOk}
+		})
+	}
+
+	start := time.Now()
+	results := r.Run(context.Background())
+	if maxRunning != 3 {
+		t.Errorf("Expecting 3 checks running at the same time, got %d", maxRunning)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Checks did not run concurrently: %s", time.Since(start))
+	}
+	for i, nr := range results {
+		if nr.Name != fmt.Sprintf("check%d", i) || nr.Result.Code != icinga.ExitOk {
+			t.Errorf("Unexpected result %d: %+v", i, nr)
+		}
+	}
+}
+
+func TestRunTimeouts(t *testing.T) {
+	r := CreateRunner(1)
+	r.Timeout = 30 * time.Millisecond
+	r.Deadline = 100 * time.Millisecond
+	r.Add("fast", sleeping(0, icinga.ExitWarning))
+	r.Add("slow", sleeping(time.Second, icinga.ExitOk))
+	r.Checks = append(r.Checks, Check{Name: "longer", Check: sleeping(time.Second, icinga.ExitOk), Timeout: time.Second})
+	r.Add("late", sleeping(0, icinga.ExitOk))
+
+	results := r.Run(context.Background())
+	for i, want := range []string{
+		"WARNING - slept 0s\n",
+		"UNKNOWN - check timed out after 30ms\n",
+		"UNKNOWN - not finished within the deadline of 100ms\n",
+		"UNKNOWN - not started within the deadline of 100ms\n",
+	} {
+		if results[i].Result.String() != want {
+			t.Errorf("Unexpected result of %s: %q", results[i].Name, results[i].Result.String())
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results = r.Run(ctx)
+	if results[0].Result.Code != icinga.ExitUnknown || results[0].Result.Message != "not started, cancelled" {
+		t.Errorf("Unexpected result %q", results[0].Result)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	r := CreateRunner(2)
+	r.AddCommand("disk", command.CreateCommand(sh, "-c", "echo 'DISK OK | /=10%;80;90'"))
+	r.AddCommand("load", command.CreateCommand(sh, "-c", "echo 'LOAD CRITICAL'; exit 2"))
+
+	merged := Merge(r.Run(context.Background()))
+	want := "CRITICAL - 2 checks: 1 OK, 1 CRITICAL | 'disk::/'=10%;80;90;;\n" +
+		"[OK] disk: DISK OK\n[CRITICAL] load: LOAD CRITICAL\n"
+	if merged.String() != want {
+		t.Errorf("Unexpected merged result %q", merged.String())
+	}
+}
+
+func TestMerge(t *testing.T) {
+	results := []NamedResult{
+		{Name: "db1", Result: icinga.Result{Code: icinga.ExitWarning, Message: "slow", LongOutput: "query 1\nquery 2\n",
+			PerfData: []perfdata.PerformanceData{*perfdata.CreatePerformanceData("pool::connections", 5, "")}}},
+		{Name: "db::2", Result: icinga.Result{Code: icinga.ExitUnknown, Message: "no connection"}},
+		{Name: "db3", Result: icinga.Result{Code: icinga.ExitOk, Message: "fine"}},
+		{Name: "", Result: icinga.Result{Code: icinga.ExitCode(7), Message: "odd",
+			PerfData: []perfdata.PerformanceData{*perfdata.CreatePerformanceData("time", 1, "s")}}},
+	}
+
+	merged := Merge(results)
+	if merged.Code != icinga.ExitWarning || merged.Message != "4 checks: 1 OK, 1 WARNING, 2 UNKNOWN" {
+		t.Errorf("Unexpected merged result %q", merged)
+	}
+	want := "[WARNING] db1: slow\n  query 1\n  query 2\n[UNKNOWN] db::2: no connection\n[OK] db3: fine\n[UNKNOWN] : odd"
+	if merged.LongOutput != want {
+		t.Errorf("Unexpected long output:\n%s", merged.LongOutput)
+	}
+	if len(merged.PerfData) != 2 || merged.PerfData[0].Label != "db1::pool::connections" || merged.PerfData[1].Label != "time" {
+		t.Errorf("Unexpected performance data %v", merged.PerfData)
+	}
+	if results[0].Result.PerfData[0].Label != "pool::connections" {
+		t.Errorf("Merge modified the results")
+	}
+
+	if m := Merge(nil); m.Code != icinga.ExitOk || m.Message != "0 checks" {
+		t.Errorf("Unexpected merged result %q", m)
+	}
+}
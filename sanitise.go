@@ -0,0 +1,61 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PipeReplacement replaces "|" in messages as it starts the performance data
+const PipeReplacement = "¦"
+
+// SanitiseSummary makes a message safe for the summary line: "|" is replaced,
+// line breaks become spaces, control characters are dropped and invalid
+// UTF-8 is replaced by U+FFFD
+func SanitiseSummary(message string) string {
+	return sanitise(message, " ")
+}
+
+// SanitiseLongOutput makes text safe for the long output like SanitiseSummary
+// but normalises line breaks to "\n" instead of removing them
+func SanitiseLongOutput(text string) string {
+	return sanitise(text, "\n")
+}
+
+func sanitise(text string, lineBreak string) string {
+	text = strings.ToValidUTF8(text, string(unicode.ReplacementChar))
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '|':
+			b.WriteString(PipeReplacement)
+		case r == '\n':
+			b.WriteString(lineBreak)
+		case r == '\t':
+			b.WriteRune(r)
+		case unicode.IsControl(r):
+			// dropped
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,67 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package icinga
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSanitiseSummary(t *testing.T) {
+	sanitiseSummary(t, "all fine", "all fine")
+	sanitiseSummary(t, "a | b", "a ¦ b")
+	sanitiseSummary(t, "line 1\nline 2", "line 1 line 2")
+	sanitiseSummary(t, "line 1\r\nline 2\rline 3", "line 1 line 2 line 3")
+	sanitiseSummary(t, "bell\a and\x00 escape\x1b[0m", "bell and escape[0m")
+	sanitiseSummary(t, "delete\x7f c1\u0085", "delete c1")
+	sanitiseSummary(t, "tab\tkept", "tab\tkept")
+	sanitiseSummary(t, "invalid \xff\xfe utf-8", "invalid � utf-8")
+	sanitiseSummary(t, "unicode äöü ✓", "unicode äöü ✓")
+}
+
+func sanitiseSummary(t *testing.T, message string, want string) {
+	t.Helper()
+	if got := SanitiseSummary(message); got != want {
+		t.Errorf("SanitiseSummary was incorrect, got: %q, want: %q.", got, want)
+	}
+}
+
+func TestSanitiseLongOutput(t *testing.T) {
+	sanitiseLongOutput(t, "line 1\nline 2", "line 1\nline 2")
+	sanitiseLongOutput(t, "line 1\r\nline 2\rline 3", "line 1\nline 2\nline 3")
+	sanitiseLongOutput(t, "a | b\x00", "a ¦ b")
+	sanitiseLongOutput(t, "\xff", "�")
+}
+
+func sanitiseLongOutput(t *testing.T, text string, want string) {
+	t.Helper()
+	if got := SanitiseLongOutput(text); got != want {
+		t.Errorf("SanitiseLongOutput was incorrect, got: %q, want: %q.", got, want)
+	}
+}
+
+func TestPrinterSanitise(t *testing.T) {
+	var buf bytes.Buffer
+	p := CreatePrinter(&buf)
+
+	p.PrintWithLongOutput("a | b\nc", "d | e\r\nf", ExitOk, nil)
+	expectOutput(t, &buf, "OK - a ¦ b c\nd ¦ e\nf\n")
+
+	p.Sanitise = false
+	p.PrintWithLongOutput("a | b", "", ExitOk, nil)
+	expectOutput(t, &buf, "OK - a | b\n")
+}
@@ -0,0 +1,285 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+	"github.com/marshei/icinga_plugins/thresholds"
+)
+
+/*
+ * Time windows
+ */
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Window of weekdays and time of day in a time zone, e.g. "mon-fri 08:00-18:00 Europe/Berlin"
+type Window struct {
+	Definition string
+	Days       [7]bool
+	Start      int
+	End        int
+	Location   *time.Location
+}
+
+// ParseWindow parses a window definition consisting of optional days, time of day and
+// time zone separated by whitespace. Days default to all days, the time of day to the
+// whole day and the time zone to the local one. A time of day ending before it starts
+// wraps around midnight and belongs to the day it started.
+func ParseWindow(windowDef string) (Window, error) {
+	var w Window
+	w.Definition = strings.Join(strings.Fields(windowDef), " ")
+	w.Start = 0
+	w.End = 24 * 60
+	w.Location = time.Local
+
+	var hasDays, hasTime, hasLocation bool
+	for _, part := range strings.Fields(windowDef) {
+		var err error
+		switch {
+		case part[0] >= '0' && part[0] <= '9':
+			if hasTime {
+				return w, errors.New("invalid window: more than one time of day")
+			}
+			hasTime = true
+			w.Start, w.End, err = parseTimeOfDay(part)
+		case isDayList(part):
+			if hasDays {
+				return w, errors.New("invalid window: more than one list of days")
+			}
+			hasDays = true
+			w.Days, err = parseDays(part)
+		default:
+			if hasLocation {
+				return w, errors.New("invalid window: more than one time zone")
+			}
+			hasLocation = true
+			w.Location, err = time.LoadLocation(part)
+		}
+		if err != nil {
+			return w, err
+		}
+	}
+
+	if !hasDays {
+		for i := range w.Days {
+			w.Days[i] = true
+		}
+	}
+
+	return w, nil
+}
+
+// Contains returns true if the time is inside the window
+func (w Window) Contains(t time.Time) bool {
+	t = t.In(w.Location)
+	minute := t.Hour()*60 + t.Minute()
+
+	if w.Start < w.End {
+		return w.Days[t.Weekday()] && minute >= w.Start && minute < w.End
+	}
+
+	// window wraps around midnight
+	if w.Days[t.Weekday()] && minute >= w.Start {
+		return true
+	}
+	return w.Days[(t.Weekday()+6)%7] && minute < w.End
+}
+
+func isDayList(def string) bool {
+	for _, r := range strings.ToLower(def) {
+		if (r < 'a' || r > 'z') && r != ',' && r != '-' {
+			return false
+		}
+	}
+	for _, day := range weekdays {
+		if strings.HasPrefix(strings.ToLower(def), day) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDays parses a list of days or day ranges, e.g. "mon-fri" or "sat,sun"
+func parseDays(def string) ([7]bool, error) {
+	var days [7]bool
+	for _, part := range strings.Split(strings.ToLower(def), ",") {
+		bounds := strings.Split(part, "-")
+		if len(bounds) > 2 {
+			return days, fmt.Errorf("invalid days: %s", part)
+		}
+		first, err := parseDay(bounds[0])
+		if err != nil {
+			return days, err
+		}
+		last := first
+		if len(bounds) == 2 {
+			last, err = parseDay(bounds[1])
+			if err != nil {
+				return days, err
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+func parseDay(def string) (int, error) {
+	for i, day := range weekdays {
+		if def == day {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid day: %s", def)
+}
+
+// parseTimeOfDay parses a time of day range, e.g. "08:00-18:00", into minutes
+func parseTimeOfDay(def string) (int, int, error) {
+	bounds := strings.Split(def, "-")
+	if len(bounds) != 2 {
+		return 0, 0, fmt.Errorf("invalid time of day: %s", def)
+	}
+	start, err := parseMinutes(bounds[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := parseMinutes(bounds[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	if start == end {
+		return 0, 0, fmt.Errorf("invalid time of day: %s", def)
+	}
+	return start, end, nil
+}
+
+func parseMinutes(def string) (int, error) {
+	parts := strings.Split(def, ":")
+	if len(parts) != 2 || len(parts[0]) == 0 || len(parts[0]) > 2 || len(parts[1]) != 2 {
+		return 0, fmt.Errorf("invalid time: %s", def)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", def)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid time: %s", def)
+	}
+	return hour*60 + minute, nil
+}
+
+/*
+ * Scheduled threshold lists
+ */
+
+// Entry is a threshold range which only applies inside the window, if set
+type Entry struct {
+	Window *Window
+	Range  icinga.ThresholdRange
+}
+
+// Schedule is a list of threshold ranges bound to time windows
+type Schedule []Entry
+
+// ParseSchedule parses a threshold list where each threshold may be prefixed by a
+// window in brackets, e.g. "[mon-fri 08:00-18:00]traffic,100;[sat,sun]traffic,500;traffic,200".
+// For each metric the first threshold whose window contains the evaluation time is used.
+func ParseSchedule(scheduleDef string) (Schedule, error) {
+	var s Schedule
+	countNoMetric := 0
+	parts := strings.FieldsFunc(scheduleDef, func(r rune) bool { return r == ';' })
+	for _, p := range parts {
+		var e Entry
+		p = strings.TrimSpace(p)
+		if strings.HasPrefix(p, "[") {
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return s, errors.New("invalid window: missing \"]\"")
+			}
+			w, err := ParseWindow(p[1:end])
+			if err != nil {
+				return s, err
+			}
+			e.Window = &w
+			p = p[end+1:]
+		}
+
+		list, err := thresholds.ParseThresholdList(p)
+		if err != nil {
+			return s, err
+		}
+		if len(list) != 1 {
+			return s, errors.New("empty range")
+		}
+		e.Range = list[0]
+		if e.Range.Metric == "" {
+			countNoMetric++
+		}
+		s = append(s, e)
+	}
+
+	if countNoMetric >= 1 && countNoMetric != len(s) {
+		return s, errors.New("missing metric")
+	}
+
+	return s, nil
+}
+
+// Select returns the threshold ranges applying at the given time
+func (s Schedule) Select(t time.Time) []icinga.ThresholdRange {
+	var list []icinga.ThresholdRange
+	var selected []string
+	for _, e := range s {
+		if e.Window != nil && !e.Window.Contains(t) {
+			continue
+		}
+		if containsMetric(selected, e.Range.Metric) {
+			continue
+		}
+		selected = append(selected, e.Range.Metric)
+		list = append(list, e.Range)
+	}
+	return list
+}
+
+func containsMetric(list []string, metric string) bool {
+	for _, m := range list {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluate selects the threshold ranges for the given time and evaluates the value against them
+func Evaluate(warning Schedule, critical Schedule, t time.Time,
+	value float64, perfData *perfdata.PerformanceData) icinga.ExitCode {
+	return thresholds.Evaluate(warning.Select(t), critical.Select(t), value, perfData)
+}
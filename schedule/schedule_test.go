@@ -0,0 +1,165 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+// 2024-01-01 was a Monday
+func utc(day int, hour int, minute int) time.Time {
+	return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestWindowContains(t *testing.T) {
+	windowContains(t, "mon-fri 08:00-18:00 UTC", utc(1, 8, 0), true)
+	windowContains(t, "mon-fri 08:00-18:00 UTC", utc(1, 17, 59), true)
+	windowContains(t, "mon-fri 08:00-18:00 UTC", utc(1, 18, 0), false)
+	windowContains(t, "mon-fri 08:00-18:00 UTC", utc(1, 7, 59), false)
+	windowContains(t, "mon-fri 08:00-18:00 UTC", utc(6, 12, 0), false)
+	windowContains(t, "sat,sun UTC", utc(6, 0, 0), true)
+	windowContains(t, "sat,sun UTC", utc(7, 23, 59), true)
+	windowContains(t, "sat,sun UTC", utc(8, 0, 0), false)
+	windowContains(t, "fri-mon UTC", utc(1, 12, 0), true)
+	windowContains(t, "fri-mon UTC", utc(2, 12, 0), false)
+	windowContains(t, "UTC 22:00-06:00", utc(3, 23, 0), true)
+	windowContains(t, "UTC 22:00-06:00", utc(3, 5, 59), true)
+	windowContains(t, "UTC 22:00-06:00", utc(3, 6, 0), false)
+	windowContains(t, "fri 22:00-06:00 UTC", utc(6, 5, 0), true)
+	windowContains(t, "fri 22:00-06:00 UTC", utc(5, 5, 0), false)
+	windowContains(t, "mon-fri 08:00-18:00 Europe/Berlin", utc(1, 7, 30), true)
+	windowContains(t, "mon-fri 08:00-18:00 Europe/Berlin", utc(1, 17, 30), false)
+	windowContains(t, "UTC 00:00-24:00", utc(1, 23, 59), true)
+}
+
+func windowContains(t *testing.T, windowDef string, at time.Time, expected bool) {
+	w, err := ParseWindow(windowDef)
+	if err != nil {
+		t.Errorf("Unexpected error for %s: %s", windowDef, err.Error())
+		return
+	}
+	if w.Contains(at) != expected {
+		t.Errorf("Window %s contains %s: expected %t", windowDef, at, expected)
+	}
+}
+
+func TestWindowError(t *testing.T) {
+	windowError(t, "mon-xyz", "invalid day: xyz")
+	windowError(t, "mon-tue-wed", "invalid days: mon-tue-wed")
+	windowError(t, "08:00", "invalid time of day: 08:00")
+	windowError(t, "08:00-08:00", "invalid time of day: 08:00-08:00")
+	windowError(t, "8-18", "invalid time: 8")
+	windowError(t, "08:60-18:00", "invalid time: 08:60")
+	windowError(t, "24:01-18:00", "invalid time: 24:01")
+	windowError(t, "mon tue", "invalid window: more than one list of days")
+	windowError(t, "08:00-10:00 12:00-14:00", "invalid window: more than one time of day")
+	windowError(t, "UTC UTC", "invalid window: more than one time zone")
+	windowError(t, "Mars/Olympus", "unknown time zone Mars/Olympus")
+}
+
+func windowError(t *testing.T, windowDef string, message string) {
+	_, err := ParseWindow(windowDef)
+	if err == nil {
+		t.Errorf("Expecting an error for %s", windowDef)
+		return
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Errorf("Expecting error: %s, got = %s", message, err.Error())
+	}
+}
+
+func TestScheduleSelect(t *testing.T) {
+	s, err := ParseSchedule("[mon-fri 08:00-18:00 UTC]traffic,100;[sat,sun UTC]traffic,500;traffic,200;load,5")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	scheduleSelect(t, s, utc(1, 12, 0), "traffic,100;load,5")
+	scheduleSelect(t, s, utc(6, 12, 0), "traffic,500;load,5")
+	scheduleSelect(t, s, utc(1, 20, 0), "traffic,200;load,5")
+}
+
+func scheduleSelect(t *testing.T, s Schedule, at time.Time, expected string) {
+	var selected []string
+	for _, r := range s.Select(at) {
+		selected = append(selected, r.Metric+","+r.Definition)
+	}
+	if strings.Join(selected, ";") != expected {
+		t.Errorf("Selection at %s was incorrect, got: %s, want: %s", at, strings.Join(selected, ";"), expected)
+	}
+}
+
+func TestScheduleError(t *testing.T) {
+	scheduleError(t, "[mon-fri traffic,100", "invalid window: missing \"]\"")
+	scheduleError(t, "[mon-fri]", "empty range")
+	scheduleError(t, "[mon-fri]traffic,100;200", "missing metric")
+	scheduleError(t, "[mon-fri]traffic,B", "parsing \"B\": invalid syntax")
+	scheduleError(t, "[noday]traffic,100", "unknown time zone noday")
+}
+
+func scheduleError(t *testing.T, scheduleDef string, message string) {
+	_, err := ParseSchedule(scheduleDef)
+	if err == nil {
+		t.Errorf("Expecting an error for %s", scheduleDef)
+		return
+	}
+	if !strings.Contains(err.Error(), message) {
+		t.Errorf("Expecting error: %s, got = %s", message, err.Error())
+	}
+}
+
+func TestScheduleWithoutMetric(t *testing.T) {
+	warning, err := ParseSchedule("[mon-fri UTC]10;20")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	if code := Evaluate(warning, nil, utc(1, 12, 0), 15, nil); code != icinga.ExitWarning {
+		t.Errorf("Expecting %s, got %s", icinga.ExitWarning, code)
+	}
+	if code := Evaluate(warning, nil, utc(6, 12, 0), 15, nil); code != icinga.ExitOk {
+		t.Errorf("Expecting %s, got %s", icinga.ExitOk, code)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	warning, _ := ParseSchedule("[mon-fri 08:00-18:00 UTC]traffic,100;traffic,200")
+	critical, _ := ParseSchedule("[mon-fri 08:00-18:00 UTC]traffic,150;traffic,300")
+
+	pd := perfdata.CreatePerformanceData("traffic", 160, "")
+	if code := Evaluate(warning, critical, utc(1, 12, 0), 160, pd); code != icinga.ExitCritical {
+		t.Errorf("Expecting %s, got %s", icinga.ExitCritical, code)
+	}
+	want := "'traffic'=160;100;150;;"
+	if pd.String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", pd.String(), want)
+	}
+
+	pd = perfdata.CreatePerformanceData("traffic", 160, "")
+	if code := Evaluate(warning, critical, utc(1, 20, 0), 160, pd); code != icinga.ExitOk {
+		t.Errorf("Expecting %s, got %s", icinga.ExitOk, code)
+	}
+	want = "'traffic'=160;200;300;;"
+	if pd.String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", pd.String(), want)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package thresholds
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+/*
+ * Compound thresholds combine several ranges of one metric:
+ *
+ *	expression = term { "|" term }
+ *	term       = factor { "&" factor }
+ *	factor     = "(" expression ")" | range
+ *
+ * "a|b" alerts if any of the ranges alerts and "a&b" alerts if both ranges
+ * alert, "&" binds stronger than "|". E.g. "5:40|@20:21" alerts below 5,
+ * above 40 and between 20 and 21.
+ */
+
+const compoundSymbols = "()|&"
+
+type compoundParser struct {
+	items []string
+	pos   int
+}
+
+// parseCompound parses a range definition without metric which may combine ranges
+func parseCompound(rangeDef string) (icinga.ThresholdRange, error) {
+	if !strings.ContainsAny(rangeDef, compoundSymbols) {
+		return parseRange(rangeDef)
+	}
+
+	p := compoundParser{items: splitCompound(rangeDef)}
+	thresholdRange, err := p.parseExpression()
+	if err != nil {
+		return thresholdRange, err
+	}
+	if p.pos < len(p.items) {
+		return thresholdRange, fmt.Errorf("invalid range: unexpected %q", p.items[p.pos])
+	}
+	return thresholdRange, nil
+}
+
+// splitCompound splits a definition into symbols and the range definitions in between
+func splitCompound(rangeDef string) []string {
+	var items []string
+	for {
+		i := strings.IndexAny(rangeDef, compoundSymbols)
+		if i < 0 {
+			break
+		}
+		if s := strings.TrimSpace(rangeDef[:i]); s != "" {
+			items = append(items, s)
+		}
+		items = append(items, rangeDef[i:i+1])
+		rangeDef = rangeDef[i+1:]
+	}
+	if s := strings.TrimSpace(rangeDef); s != "" {
+		items = append(items, s)
+	}
+	return items
+}
+
+func (p *compoundParser) peek() string {
+	if p.pos < len(p.items) {
+		return p.items[p.pos]
+	}
+	return ""
+}
+
+func (p *compoundParser) parseExpression() (icinga.ThresholdRange, error) {
+	left, err := p.parseTerm()
+	for err == nil && p.peek() == "|" {
+		p.pos++
+		var right icinga.ThresholdRange
+		right, err = p.parseTerm()
+		left = combineRanges(icinga.OperatorOr, left, right)
+	}
+	return left, err
+}
+
+func (p *compoundParser) parseTerm() (icinga.ThresholdRange, error) {
+	left, err := p.parseFactor()
+	for err == nil && p.peek() == "&" {
+		p.pos++
+		var right icinga.ThresholdRange
+		right, err = p.parseFactor()
+		left = combineRanges(icinga.OperatorAnd, left, right)
+	}
+	return left, err
+}
+
+func (p *compoundParser) parseFactor() (icinga.ThresholdRange, error) {
+	item := p.peek()
+	switch item {
+	case "":
+		return icinga.ThresholdRange{}, errors.New("empty range")
+	case "(":
+		p.pos++
+		thresholdRange, err := p.parseExpression()
+		if err != nil {
+			return thresholdRange, err
+		}
+		if p.peek() != ")" {
+			return thresholdRange, errors.New("invalid range: missing \")\"")
+		}
+		p.pos++
+		return thresholdRange, nil
+	case ")", "|", "&":
+		return icinga.ThresholdRange{}, fmt.Errorf("invalid range: unexpected %q", item)
+	}
+	p.pos++
+	return parseRange(item)
+}
+
+func combineRanges(op icinga.RangeOperator, left icinga.ThresholdRange, right icinga.ThresholdRange) icinga.ThresholdRange {
+	l := left.Definition
+	if left.Operator == icinga.OperatorOr && op == icinga.OperatorAnd {
+		l = "(" + l + ")"
+	}
+	r := right.Definition
+	if right.Operator == op || right.Operator == icinga.OperatorOr {
+		r = "(" + r + ")"
+	}
+
+	return icinga.ThresholdRange{
+		Definition: l + op.String() + r,
+		Operator:   op,
+		Left:       &left,
+		Right:      &right,
+	}
+}
@@ -0,0 +1,125 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package thresholds
+
+import (
+	"testing"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func TestCompoundParse(t *testing.T) {
+	r, err := parseThreshold("temperature,5:40|@20:21")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	if r.Metric != "temperature" || r.Operator != icinga.OperatorOr || r.Definition != "5:40|@20:21" {
+		t.Errorf("Unexpected compound range: %s %s %s", r.Metric, r.Operator, r.Definition)
+	}
+
+	var e icinga.ThresholdRange
+	e.Definition = "5:40"
+	e.Inside = true
+	e.Start = 5
+	e.End = 40
+	expectRangeSuccess(t, *r.Left, e, nil)
+
+	e.Definition = "@20:21"
+	e.Inside = false
+	e.Start = 20
+	e.End = 21
+	expectRangeSuccess(t, *r.Right, e, nil)
+}
+
+func TestCompoundDefinition(t *testing.T) {
+	compoundDefinition(t, "1:2 | 3:4 | 5:6", "1:2|3:4|5:6")
+	compoundDefinition(t, "1:2|3:4&5:6", "1:2|3:4&5:6")
+	compoundDefinition(t, "(1:2|3:4)&5:6", "(1:2|3:4)&5:6")
+	compoundDefinition(t, "1:2&(3:4|5:6)", "1:2&(3:4|5:6)")
+	compoundDefinition(t, "1:2|(3:4|5:6)", "1:2|(3:4|5:6)")
+	compoundDefinition(t, "((10:20))", "10:20")
+}
+
+func compoundDefinition(t *testing.T, rangeDef string, expected string) {
+	r, err := parseCompound(rangeDef)
+	if err != nil {
+		t.Errorf("Unexpected error for %s: %s", rangeDef, err.Error())
+		return
+	}
+	if r.Definition != expected {
+		t.Errorf("Difference for Definition: curr = %s, expected = %s", r.Definition, expected)
+	}
+
+	again, err := parseCompound(r.Definition)
+	if err != nil || !sameRange(again, r) {
+		t.Errorf("Definition %s does not parse into the same range", r.Definition)
+	}
+}
+
+func sameRange(a icinga.ThresholdRange, b icinga.ThresholdRange) bool {
+	if a.Operator != b.Operator || a.Definition != b.Definition {
+		return false
+	}
+	if a.IsCompound() {
+		return sameRange(*a.Left, *b.Left) && sameRange(*a.Right, *b.Right)
+	}
+	return a.Inside == b.Inside && a.Start == b.Start && a.End == b.End
+}
+
+func TestCompoundError(t *testing.T) {
+	rangeError(t, "5:40|", "empty range")
+	rangeError(t, "|5:40", "invalid range: unexpected \"|\"")
+	rangeError(t, "5:40||1", "invalid range: unexpected \"|\"")
+	rangeError(t, "(5:40", "invalid range: missing \")\"")
+	rangeError(t, "5:40)", "invalid range: unexpected \")\"")
+	rangeError(t, "()", "invalid range: unexpected \")\"")
+	rangeError(t, "5:40&B", "parsing \"B\": invalid syntax")
+	rangeError(t, "5:40|40:5", "invalid range: start greater than end")
+}
+
+func TestCompoundEvaluation(t *testing.T) {
+	evaluateRange(t, "5:40|@20:21", 4, true)
+	evaluateRange(t, "5:40|@20:21", 10, false)
+	evaluateRange(t, "5:40|@20:21", 20.5, true)
+	evaluateRange(t, "5:40|@20:21", 41, true)
+	evaluateRange(t, "@10:|@~:0", 5, false)
+	evaluateRange(t, "@10:&@~:20", 5, false)
+	evaluateRange(t, "@10:&@~:20", 15, true)
+	evaluateRange(t, "(@10:|@~:0)&@~:20", 15, true)
+	evaluateRange(t, "(@10:|@~:0)&@~:20", 25, false)
+}
+
+func TestCompoundEvaluate(t *testing.T) {
+	warning, _ := ParseThresholdList("temperature,10:30")
+	critical, _ := ParseThresholdList("temperature,5:40|@20:21")
+
+	pd := perfdata.CreatePerformanceData("temperature", 20.5, "C")
+	if code := Evaluate(warning, critical, 20.5, pd); code != icinga.ExitCritical {
+		t.Errorf("Expecting %s, got %s", icinga.ExitCritical, code)
+	}
+
+	want := "'temperature'=20.5C;10:30;;;"
+	if pd.String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", pd.String(), want)
+	}
+
+	if code := Evaluate(warning, critical, 25, pd); code != icinga.ExitOk {
+		t.Errorf("Expecting %s, got %s", icinga.ExitOk, code)
+	}
+}
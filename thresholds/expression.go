@@ -0,0 +1,379 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package thresholds
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+/*
+ * Expressions compute a derived value from the performance data labels:
+ *
+ *	expression = term { ( "+" | "-" ) term }
+ *	term       = unary { ( "*" | "/" ) unary }
+ *	unary      = [ "-" ] primary
+ *	primary    = number | label | "(" expression ")"
+ *	label      = ( letter | "_" ) { letter | digit | "_" | "." | ":" }
+ *	           | "'" { any character except "'" } "'"
+ *
+ * They can be used as metric of a threshold, e.g. "used/total,0:0.9" or
+ * "errors_in+errors_out,100".
+ */
+
+// Expression over performance data labels
+type Expression struct {
+	Definition string
+	root       expressionNode
+}
+
+type expressionNode interface {
+	value(perfDataList []perfdata.PerformanceData) (float64, error)
+	labels() []string
+}
+
+type numberNode float64
+
+type labelNode string
+
+type negateNode struct {
+	operand expressionNode
+}
+
+type binaryNode struct {
+	operator    byte
+	left, right expressionNode
+}
+
+// ParseExpression parses the provided string into an expression
+func ParseExpression(definition string) (*Expression, error) {
+	items, err := tokenizeExpression(definition)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, errors.New("empty expression")
+	}
+
+	p := expressionParser{items: items}
+	root, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.items) {
+		return nil, fmt.Errorf("invalid expression: unexpected %q", p.items[p.pos].text)
+	}
+
+	return &Expression{Definition: strings.TrimSpace(definition), root: root}, nil
+}
+
+// Value computes the expression for the given performance data
+func (e *Expression) Value(perfDataList []perfdata.PerformanceData) (float64, error) {
+	value, err := e.root.value(perfDataList)
+	if err != nil {
+		return value, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return value, fmt.Errorf("invalid value of expression %s", e.Definition)
+	}
+	return value, nil
+}
+
+// Labels returns the performance data labels referenced by the expression
+func (e *Expression) Labels() []string {
+	return e.root.labels()
+}
+
+func (n numberNode) value(perfDataList []perfdata.PerformanceData) (float64, error) {
+	return float64(n), nil
+}
+
+func (n numberNode) labels() []string {
+	return nil
+}
+
+func (n labelNode) value(perfDataList []perfdata.PerformanceData) (float64, error) {
+	for _, pd := range perfDataList {
+		if pd.Label == string(n) {
+			return pd.Value, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown label %q", string(n))
+}
+
+func (n labelNode) labels() []string {
+	return []string{string(n)}
+}
+
+func (n negateNode) value(perfDataList []perfdata.PerformanceData) (float64, error) {
+	v, err := n.operand.value(perfDataList)
+	return -v, err
+}
+
+func (n negateNode) labels() []string {
+	return n.operand.labels()
+}
+
+func (n binaryNode) value(perfDataList []perfdata.PerformanceData) (float64, error) {
+	l, err := n.left.value(perfDataList)
+	if err != nil {
+		return 0, err
+	}
+	r, err := n.right.value(perfDataList)
+	if err != nil {
+		return 0, err
+	}
+
+	switch n.operator {
+	case '+':
+		return l + r, nil
+	case '-':
+		return l - r, nil
+	case '*':
+		return l * r, nil
+	default:
+		if r == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return l / r, nil
+	}
+}
+
+func (n binaryNode) labels() []string {
+	return append(n.left.labels(), n.right.labels()...)
+}
+
+/*
+ * Tokenizer and parser of expressions
+ */
+
+type expressionKind int
+
+const (
+	tokenNumber expressionKind = iota
+	tokenLabel
+	tokenSymbol
+)
+
+type expressionToken struct {
+	kind  expressionKind
+	text  string
+	value float64
+}
+
+func tokenizeExpression(definition string) ([]expressionToken, error) {
+	var items []expressionToken
+	for i := 0; i < len(definition); {
+		c := definition[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("+-*/()", c) >= 0:
+			items = append(items, expressionToken{kind: tokenSymbol, text: definition[i : i+1]})
+			i++
+		case c == '\'':
+			j := strings.IndexByte(definition[i+1:], '\'')
+			if j < 0 {
+				return items, errors.New("invalid expression: missing \"'\"")
+			}
+			if j == 0 {
+				return items, errors.New("invalid expression: empty label")
+			}
+			items = append(items, expressionToken{kind: tokenLabel, text: definition[i+1 : i+1+j]})
+			i += j + 2
+		case c == '.' || (c >= '0' && c <= '9'):
+			j := i
+			for j < len(definition) && (isNumberByte(definition[j]) ||
+				((definition[j] == '+' || definition[j] == '-') && (definition[j-1] == 'e' || definition[j-1] == 'E'))) {
+				j++
+			}
+			if !isNumber(definition[i:j]) {
+				return items, &strconv.NumError{Func: "ParseFloat", Num: definition[i:j], Err: strconv.ErrSyntax}
+			}
+			value, err := strconv.ParseFloat(definition[i:j], 64)
+			if err != nil {
+				return items, err
+			}
+			items = append(items, expressionToken{kind: tokenNumber, text: definition[i:j], value: value})
+			i = j
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+			j := i
+			for j < len(definition) && isLabelByte(definition[j]) {
+				j++
+			}
+			items = append(items, expressionToken{kind: tokenLabel, text: definition[i:j]})
+			i = j
+		default:
+			return items, fmt.Errorf("invalid expression: unexpected character %q", c)
+		}
+	}
+	return items, nil
+}
+
+func isNumberByte(c byte) bool {
+	return c == '.' || c == 'e' || c == 'E' || (c >= '0' && c <= '9')
+}
+
+func isLabelByte(c byte) bool {
+	return c == '_' || c == '.' || c == ':' ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+type expressionParser struct {
+	items []expressionToken
+	pos   int
+}
+
+func (p *expressionParser) isSymbol(symbols string) (byte, bool) {
+	if p.pos < len(p.items) && p.items[p.pos].kind == tokenSymbol && strings.Contains(symbols, p.items[p.pos].text) {
+		return p.items[p.pos].text[0], true
+	}
+	return 0, false
+}
+
+func (p *expressionParser) parseSum() (expressionNode, error) {
+	left, err := p.parseProduct()
+	for err == nil {
+		operator, ok := p.isSymbol("+-")
+		if !ok {
+			break
+		}
+		p.pos++
+		var right expressionNode
+		right, err = p.parseProduct()
+		left = binaryNode{operator: operator, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *expressionParser) parseProduct() (expressionNode, error) {
+	left, err := p.parseUnary()
+	for err == nil {
+		operator, ok := p.isSymbol("*/")
+		if !ok {
+			break
+		}
+		p.pos++
+		var right expressionNode
+		right, err = p.parseUnary()
+		left = binaryNode{operator: operator, left: left, right: right}
+	}
+	return left, err
+}
+
+func (p *expressionParser) parseUnary() (expressionNode, error) {
+	if _, ok := p.isSymbol("-"); ok {
+		p.pos++
+		operand, err := p.parsePrimary()
+		return negateNode{operand: operand}, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *expressionParser) parsePrimary() (expressionNode, error) {
+	if p.pos >= len(p.items) {
+		return nil, errors.New("invalid expression: unexpected end")
+	}
+
+	item := p.items[p.pos]
+	p.pos++
+	switch item.kind {
+	case tokenNumber:
+		return numberNode(item.value), nil
+	case tokenLabel:
+		return labelNode(item.text), nil
+	}
+
+	if item.text != "(" {
+		return nil, fmt.Errorf("invalid expression: unexpected %q", item.text)
+	}
+	node, err := p.parseSum()
+	if err != nil {
+		return node, err
+	}
+	if _, ok := p.isSymbol(")"); !ok {
+		return node, errors.New("invalid expression: missing \")\"")
+	}
+	p.pos++
+	return node, nil
+}
+
+/*
+ * Evaluate thresholds with expressions as metric
+ */
+
+// EvaluateExpressions evaluates all thresholds whose metric is not a label of the
+// performance data, nor the metric of labels like "db1::connections", but an expression
+// over these labels. It returns the combined
+// exit code and a performance data object per expression with its value and thresholds.
+func EvaluateExpressions(warningList []icinga.ThresholdRange, criticalList []icinga.ThresholdRange,
+	perfDataList []perfdata.PerformanceData) (icinga.ExitCode, []perfdata.PerformanceData, error) {
+
+	code := icinga.ExitOk
+	var derived []perfdata.PerformanceData
+	var seen []string
+
+	for _, list := range [][]icinga.ThresholdRange{warningList, criticalList} {
+		for _, tr := range list {
+			if tr.Metric == "" || containsString(seen, tr.Metric) || hasLabel(perfDataList, tr.Metric) {
+				continue
+			}
+			seen = append(seen, tr.Metric)
+
+			expression, err := ParseExpression(tr.Metric)
+			if err != nil {
+				return icinga.ExitUnknown, derived, err
+			}
+			value, err := expression.Value(perfDataList)
+			if err != nil {
+				return icinga.ExitUnknown, derived, err
+			}
+
+			pd := perfdata.CreatePerformanceData(tr.Metric, value, "")
+			code = code.GetResultCode(Evaluate(warningList, criticalList, value, pd))
+			derived = append(derived, *pd)
+		}
+	}
+
+	return code, derived, nil
+}
+
+// hasLabel returns whether a threshold of the label applies to the performance data
+// directly or, like in getThreshold, as metric of labels like "db1::connections"
+func hasLabel(perfDataList []perfdata.PerformanceData, label string) bool {
+	for _, pd := range perfDataList {
+		if pd.Label == label || perfdata.Metric(pd.Label) == label {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package thresholds
+
+import (
+	"strings"
+	"testing"
+
+	icinga "github.com/marshei/icinga_plugins"
+	"github.com/marshei/icinga_plugins/perfdata"
+)
+
+func testPerfData() []perfdata.PerformanceData {
+	return []perfdata.PerformanceData{
+		*perfdata.CreatePerformanceData("used", 95, "GB"),
+		*perfdata.CreatePerformanceData("total", 100, "GB"),
+		*perfdata.CreatePerformanceData("errors_in", 60, "c"),
+		*perfdata.CreatePerformanceData("errors_out", 50, "c"),
+		*perfdata.CreatePerformanceData("db1::connections", 8, ""),
+		*perfdata.CreatePerformanceData("queue length", 3, ""),
+		*perfdata.CreatePerformanceData("zero", 0, ""),
+	}
+}
+
+func TestExpressionValue(t *testing.T) {
+	expressionValue(t, "used/total", 0.95)
+	expressionValue(t, "errors_in + errors_out", 110)
+	expressionValue(t, "errors_in - errors_out * 2", -40)
+	expressionValue(t, "(errors_in - errors_out) * 2", 20)
+	expressionValue(t, "-used + total", 5)
+	expressionValue(t, "used / total * 100", 95)
+	expressionValue(t, "db1::connections / 2", 4)
+	expressionValue(t, "'queue length' * 1e1", 30)
+	expressionValue(t, "10 - 4 - 3", 3)
+	expressionValue(t, "used/.5e1", 19)
+}
+
+func expressionValue(t *testing.T, definition string, expected float64) {
+	e, err := ParseExpression(definition)
+	if err != nil {
+		t.Errorf("Unexpected error for %s: %s", definition, err.Error())
+		return
+	}
+
+	value, err := e.Value(testPerfData())
+	if err != nil {
+		t.Errorf("Unexpected error for %s: %s", definition, err.Error())
+	}
+	if value != expected {
+		t.Errorf("Value of %s was incorrect, got: %f, want: %f.", definition, value, expected)
+	}
+}
+
+func TestExpressionLabels(t *testing.T) {
+	e, err := ParseExpression("(errors_in + 'queue length') / total")
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+
+	labels := strings.Join(e.Labels(), ",")
+	if labels != "errors_in,queue length,total" {
+		t.Errorf("Unexpected labels: %s", labels)
+	}
+}
+
+func TestExpressionParseError(t *testing.T) {
+	expressionError(t, "", "empty expression")
+	expressionError(t, "used +", "invalid expression: unexpected end")
+	expressionError(t, "used total", "invalid expression: unexpected \"total\"")
+	expressionError(t, "(used", "invalid expression: missing \")\"")
+	expressionError(t, "used)", "invalid expression: unexpected \")\"")
+	expressionError(t, "* used", "invalid expression: unexpected \"*\"")
+	expressionError(t, "'used", "invalid expression: missing \"'\"")
+	expressionError(t, "''", "invalid expression: empty label")
+	expressionError(t, "used % total", "invalid expression: unexpected character '%'")
+	expressionError(t, "1.2.3", "parsing \"1.2.3\": invalid syntax")
+}
+
+func expressionError(t *testing.T, definition string, message string) {
+	_, err := ParseExpression(definition)
+	expectRangeError(t, message, err)
+}
+
+func TestExpressionValueError(t *testing.T) {
+	expressionValueError(t, "used / missing", "unknown label \"missing\"")
+	expressionValueError(t, "used / zero", "division by zero")
+	expressionValueError(t, "used * 1e308 * 10", "invalid value of expression used * 1e308 * 10")
+}
+
+func expressionValueError(t *testing.T, definition string, message string) {
+	e, err := ParseExpression(definition)
+	if err != nil {
+		t.Errorf("Unexpected error for %s: %s", definition, err.Error())
+		return
+	}
+	_, err = e.Value(testPerfData())
+	expectRangeError(t, message, err)
+}
+
+func TestEvaluateExpressions(t *testing.T) {
+	warning, _ := ParseThresholdList("used/total,0:0.9;total,1000")
+	critical, _ := ParseThresholdList("used/total,0:0.99;errors_in+errors_out,100")
+
+	code, derived, err := EvaluateExpressions(warning, critical, testPerfData())
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if code != icinga.ExitCritical {
+		t.Errorf("Expecting %s, got %s", icinga.ExitCritical, code)
+	}
+
+	if len(derived) != 2 {
+		t.Fatalf("Expecting %d derived performance data, got %d", 2, len(derived))
+	}
+	want := "'used/total'=0.95;0:0.9;0:0.99;;"
+	if derived[0].String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", derived[0].String(), want)
+	}
+	want = "'errors_in+errors_out'=110;;100;;"
+	if derived[1].String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", derived[1].String(), want)
+	}
+
+	critical, _ = ParseThresholdList("used/total,0:0.99")
+	code, _, _ = EvaluateExpressions(warning, critical, testPerfData())
+	if code != icinga.ExitWarning {
+		t.Errorf("Expecting %s, got %s", icinga.ExitWarning, code)
+	}
+}
+
+func TestEvaluateExpressionsWithInstances(t *testing.T) {
+	list := []perfdata.PerformanceData{
+		*perfdata.CreatePerformanceData("db1::connections", 120, ""),
+		*perfdata.CreatePerformanceData("db2::connections", 50, ""),
+		*perfdata.CreatePerformanceData("db1::max", 200, ""),
+	}
+	warning, _ := ParseThresholdList("connections,100;db1::connections/db1::max,0:0.5")
+	critical, _ := ParseThresholdList("connections,150")
+
+	code, derived, err := EvaluateExpressions(warning, critical, list)
+	if err != nil {
+		t.Fatalf("Unexpected error occured: %s", err.Error())
+	}
+	if code != icinga.ExitWarning || len(derived) != 1 {
+		t.Fatalf("Expecting %s with one derived performance data, got %s with %d", icinga.ExitWarning, code, len(derived))
+	}
+	want := "'db1::connections/db1::max'=0.6;0:0.5;;;"
+	if derived[0].String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", derived[0].String(), want)
+	}
+
+	// the instance thresholds still apply to the performance data itself
+	if code := Evaluate(warning, critical, list[0].Value, &list[0]); code != icinga.ExitWarning {
+		t.Errorf("Expecting %s, got %s", icinga.ExitWarning, code)
+	}
+}
+
+func TestEvaluateExpressionsError(t *testing.T) {
+	warning, _ := ParseThresholdList("used/free,0:0.9")
+
+	code, _, err := EvaluateExpressions(warning, nil, testPerfData())
+	expectRangeError(t, "unknown label \"free\"", err)
+	if code != icinga.ExitUnknown {
+		t.Errorf("Expecting %s, got %s", icinga.ExitUnknown, code)
+	}
+}
@@ -0,0 +1,231 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package thresholds
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	icinga "github.com/marshei/icinga_plugins"
+)
+
+/*
+ * Grammar of a single threshold range, whitespace is allowed between tokens:
+ *
+ *	range  = [ "@" ] bounds
+ *	bounds = value | [ start ] ":" [ end ]
+ *	start  = "~" | number
+ *	end    = "~" | number
+ *	value  = number
+ *	number = [ "+" | "-" ] ( digits [ "." [ digits ] ] | "." digits ) [ exponent ]
+ *	       | [ "+" | "-" ] "inf"
+ *
+ * A "~" or a missing bound stands for infinity in the direction of the bound,
+ * i.e. -Inf as start and +Inf as end. A single value is the end of a range
+ * starting at 0. A "~" on its own is rejected as it could be either bound.
+ */
+
+type tokenKind int
+
+const (
+	tokenAt tokenKind = iota
+	tokenColon
+	tokenWord
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// tokenize splits a range definition into "@", ":" and value words
+func tokenize(rangeDef string) ([]token, error) {
+	var tokens []token
+	runes := []rune(rangeDef)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '@':
+			tokens = append(tokens, token{kind: tokenAt, text: "@"})
+			i++
+		case r == ':':
+			tokens = append(tokens, token{kind: tokenColon, text: ":"})
+			i++
+		case isWordRune(r):
+			j := i
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: string(runes[i:j])})
+			i = j
+		default:
+			return tokens, fmt.Errorf("invalid range: unexpected character %q", r)
+		}
+	}
+	return tokens, nil
+}
+
+func isWordRune(r rune) bool {
+	return r == '~' || r == '.' || r == '+' || r == '-' ||
+		(r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+// parseRange parses a range definition without metric according to the grammar above
+func parseRange(rangeDef string) (icinga.ThresholdRange, error) {
+	var thresholdRange icinga.ThresholdRange
+	thresholdRange.Inside = true
+	thresholdRange.Start = math.Inf(-1)
+	thresholdRange.End = math.Inf(1)
+
+	tokens, err := tokenize(rangeDef)
+	if err != nil {
+		return thresholdRange, err
+	}
+
+	var definition strings.Builder
+	for _, t := range tokens {
+		definition.WriteString(t.text)
+	}
+	thresholdRange.Definition = definition.String()
+
+	if len(tokens) > 0 && tokens[0].kind == tokenAt {
+		thresholdRange.Inside = false
+		tokens = tokens[1:]
+	}
+
+	if len(tokens) == 0 {
+		return thresholdRange, errors.New("empty range")
+	}
+
+	colon := -1
+	for i, t := range tokens {
+		switch t.kind {
+		case tokenAt:
+			return thresholdRange, errors.New("invalid range: unexpected \"@\"")
+		case tokenColon:
+			if colon >= 0 {
+				return thresholdRange, errors.New("invalid range: too many values")
+			}
+			colon = i
+		}
+	}
+
+	if colon < 0 {
+		if len(tokens) > 1 {
+			return thresholdRange, fmt.Errorf("invalid range: unexpected %q", tokens[1].text)
+		}
+		if tokens[0].text == "~" {
+			return thresholdRange, errors.New("invalid range: ambiguous \"~\"")
+		}
+		// A single number will be interpreted as end with a start of 0
+		thresholdRange.Start = 0
+		thresholdRange.End, err = stringToFloat(tokens[0].text)
+		if err != nil {
+			return thresholdRange, err
+		}
+		return validateThreshold(thresholdRange)
+	}
+
+	startTokens, endTokens := tokens[:colon], tokens[colon+1:]
+	if len(startTokens) == 0 && len(endTokens) == 0 {
+		return thresholdRange, errors.New("empty range")
+	}
+	if len(startTokens) > 1 {
+		return thresholdRange, fmt.Errorf("invalid range: unexpected %q", startTokens[1].text)
+	}
+	if len(endTokens) > 1 {
+		return thresholdRange, fmt.Errorf("invalid range: unexpected %q", endTokens[1].text)
+	}
+
+	if len(startTokens) == 1 {
+		thresholdRange.Start, err = stringToFloat(startTokens[0].text)
+		if err != nil {
+			return thresholdRange, err
+		}
+	}
+	if len(endTokens) == 1 && endTokens[0].text != "~" {
+		thresholdRange.End, err = stringToFloat(endTokens[0].text)
+		if err != nil {
+			return thresholdRange, err
+		}
+	}
+
+	return validateThreshold(thresholdRange)
+}
+
+// stringToFloat converts a start value or number of a range, "~" is -Inf
+func stringToFloat(value string) (float64, error) {
+	if value == "" {
+		return 0, errors.New("empty value")
+	}
+
+	if value == "~" {
+		return math.Inf(-1), nil
+	}
+
+	if !isNumber(value) {
+		return 0, &strconv.NumError{Func: "ParseFloat", Num: value, Err: strconv.ErrSyntax}
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
+
+// isNumber checks a value against the number rule of the grammar, as
+// strconv.ParseFloat alone would also accept hex floats, underscores and NaN
+func isNumber(value string) bool {
+	s := strings.TrimLeft(value, "+-")
+	if len(value)-len(s) > 1 {
+		return false
+	}
+	if strings.EqualFold(s, "inf") {
+		return true
+	}
+
+	digits := func() int {
+		n := 0
+		for len(s) > 0 && s[0] >= '0' && s[0] <= '9' {
+			s = s[1:]
+			n++
+		}
+		return n
+	}
+
+	n := digits()
+	if strings.HasPrefix(s, ".") {
+		s = s[1:]
+		n += digits()
+	}
+	if n == 0 {
+		return false
+	}
+	if strings.HasPrefix(s, "e") || strings.HasPrefix(s, "E") {
+		s = s[1:]
+		if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+			s = s[1:]
+		}
+		if digits() == 0 {
+			return false
+		}
+	}
+	return s == ""
+}
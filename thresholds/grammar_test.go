@@ -0,0 +1,103 @@
+/*
+	This file is part of icinga_plugins.
+
+Icinga Plugins Support is free software: you can redistribute it and/or modify
+it under the terms of the GNU General Public License as published by
+the Free Software Foundation, either version 3 of the License, or
+(at your option) any later version.
+
+Icinga Plugins Support is distributed in the hope that it will be useful,
+but WITHOUT ANY WARRANTY; without even the implied warranty of
+MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+GNU General Public License for more details.
+
+You should have received a copy of the GNU General Public License
+along with Icinga Plugins Support.  If not, see <http://www.gnu.org/licenses/>.
+*/
+package thresholds
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGrammarSuccess(t *testing.T) {
+	rangeSuccess(t, "~:", "~:", "", true, math.Inf(-1), math.Inf(1))
+	rangeSuccess(t, "@~:", "@~:", "", false, math.Inf(-1), math.Inf(1))
+	rangeSuccess(t, "10:~", "10:~", "", true, 10, math.Inf(1))
+	rangeSuccess(t, "~:~", "~:~", "", true, math.Inf(-1), math.Inf(1))
+	rangeSuccess(t, "-inf:inf", "-inf:inf", "", true, math.Inf(-1), math.Inf(1))
+	rangeSuccess(t, "10:+INF", "10:+INF", "", true, 10, math.Inf(1))
+	rangeSuccess(t, "inf", "inf", "", true, 0, math.Inf(1))
+	rangeSuccess(t, "-20:-10", "-20:-10", "", true, -20, -10)
+	rangeSuccess(t, "+1.5:.5e1", "+1.5:.5e1", "", true, 1.5, 5)
+	rangeSuccess(t, "1e3", "1e3", "", true, 0, 1000)
+	rangeSuccess(t, "1E-3:2.E+2", "1E-3:2.E+2", "", true, 0.001, 200)
+	rangeSuccess(t, " @ 10 : 20 ", "@10:20", "", false, 10, 20)
+	rangeSuccess(t, " metric , 10: ", "10:", "metric", true, 10, math.Inf(1))
+}
+
+func TestGrammarError(t *testing.T) {
+	rangeError(t, "   ", "empty range")
+	rangeError(t, "@ :", "empty range")
+	rangeError(t, "~", "invalid range: ambiguous \"~\"")
+	rangeError(t, "@~", "invalid range: ambiguous \"~\"")
+	rangeError(t, "10:~:20", "invalid range: too many values")
+	rangeError(t, "10 20", "invalid range: unexpected \"20\"")
+	rangeError(t, "10:20 30", "invalid range: unexpected \"30\"")
+	rangeError(t, "~ 10:20", "invalid range: unexpected \"10\"")
+	rangeError(t, "10@", "invalid range: unexpected \"@\"")
+	rangeError(t, "10:20#", "invalid range: unexpected character '#'")
+	rangeError(t, "nan", "parsing \"nan\": invalid syntax")
+	rangeError(t, "0x10", "parsing \"0x10\": invalid syntax")
+	rangeError(t, "1_000", "invalid range: unexpected character '_'")
+	rangeError(t, "--1:2", "parsing \"--1\": invalid syntax")
+	rangeError(t, "1e", "parsing \"1e\": invalid syntax")
+	rangeError(t, ".", "parsing \".\": invalid syntax")
+	rangeError(t, "10-20", "parsing \"10-20\": invalid syntax")
+	rangeError(t, "infinity", "parsing \"infinity\": invalid syntax")
+	rangeError(t, "1e999", "value out of range")
+	rangeError(t, "inf:10", "invalid range: start greater than end")
+	rangeError(t, "10:-inf", "invalid range: start greater than end")
+}
+
+func TestIsNumber(t *testing.T) {
+	for _, n := range []string{"0", "-1", "+1", "1.", ".1", "1.5e10", "1e-2", "INF", "-Inf"} {
+		if !isNumber(n) {
+			t.Errorf("Expecting %q to be a number", n)
+		}
+	}
+	for _, n := range []string{"", "+", "-", "+-1", "e1", "1e+", "1.2.3", "NaN", "0x1p-2", "~"} {
+		if isNumber(n) {
+			t.Errorf("Expecting %q not to be a number", n)
+		}
+	}
+}
+
+func FuzzParseRange(f *testing.F) {
+	for _, seed := range []string{"10", "10:", "~:10", ":10", "10:20", "@10:20", "@~:", "~:",
+		"-inf:inf", "1e3:1E4", " @ -1.5 : .5 ", "~", ":", "@", "1:2:3", "nan", "0x10"} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, rangeDef string) {
+		r, err := parseRange(rangeDef)
+		if err != nil {
+			return
+		}
+		if math.IsNaN(r.Start) || math.IsNaN(r.End) {
+			t.Fatalf("NaN bound for %q: %f:%f", rangeDef, r.Start, r.End)
+		}
+		if r.Start > r.End {
+			t.Fatalf("Start greater than end for %q: %f:%f", rangeDef, r.Start, r.End)
+		}
+
+		// the normalised definition must parse into the same range
+		again, err := parseRange(r.Definition)
+		if err != nil {
+			t.Fatalf("Definition %q of %q does not parse: %s", r.Definition, rangeDef, err.Error())
+		}
+		if again != r {
+			t.Fatalf("Definition %q of %q parses differently: %v != %v", r.Definition, rangeDef, again, r)
+		}
+	})
+}
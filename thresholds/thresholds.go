@@ -19,7 +19,6 @@ package thresholds
 import (
 	"errors"
 	"math"
-	"strconv"
 	"strings"
 
 	icinga "github.com/marshei/icinga_plugins"
@@ -54,125 +53,115 @@ func ParseThresholdList(thresholdDef string) ([]icinga.ThresholdRange, error) {
 }
 
 func parseThreshold(thresholdDef string) (icinga.ThresholdRange, error) {
-	var err error
 	var thresholdRange icinga.ThresholdRange
 	thresholdRange.Inside = true
 	thresholdRange.Start = math.Inf(-1)
 	thresholdRange.End = math.Inf(1)
 
-	if strings.HasPrefix(thresholdDef, ",") {
+	if strings.HasPrefix(strings.TrimSpace(thresholdDef), ",") {
 		return thresholdRange, errors.New("empty metric")
 	}
 
 	rangeDef := thresholdDef
+	metric := ""
 	// get metric name if given
 	if strings.Contains(rangeDef, ",") {
 		s1 := strings.FieldsFunc(rangeDef, func(r rune) bool { return r == ',' })
 		if len(s1) != 2 {
 			return thresholdRange, errors.New("invalid metric")
 		}
-		thresholdRange.Metric = s1[0]
+		metric = strings.TrimSpace(s1[0])
 		rangeDef = s1[1]
 	}
 
-	thresholdRange.Definition = rangeDef
-	thresholdRange.Inside = !strings.HasPrefix(rangeDef, "@")
-	rangeDef = strings.TrimPrefix(rangeDef, "@")
-
-	if rangeDef == "" {
-		return thresholdRange, errors.New("empty range")
-	}
-
-	if !strings.Contains(rangeDef, ":") {
-		// A single number will be interpreted as end with a start of 0
-		thresholdRange.Start = 0
-		thresholdRange.End, err = stringToFloat(rangeDef)
-
-		if err != nil {
-			return thresholdRange, err
-		}
-		return validateThreshold(thresholdRange)
-	}
-
-	s := strings.FieldsFunc(rangeDef, func(r rune) bool { return r == ':' })
-
-	if len(s) == 0 {
-		return thresholdRange, errors.New("empty range")
-	}
-
-	if len(s) > 2 {
-		return thresholdRange, errors.New("invalid range: too many values")
-	}
+	thresholdRange, err := parseCompound(rangeDef)
+	thresholdRange.Metric = metric
+	return thresholdRange, err
+}
 
-	if len(s) == 1 {
-		if strings.HasSuffix(rangeDef, ":") {
-			thresholdRange.Start, err = stringToFloat(s[0])
-			if err != nil {
-				return thresholdRange, err
-			}
-		} else {
-			thresholdRange.End, err = stringToFloat(s[0])
-			if err != nil {
-				return thresholdRange, err
-			}
-		}
-	} else {
-		thresholdRange.Start, err = stringToFloat(s[0])
-		if err != nil {
-			return thresholdRange, err
-		}
-		thresholdRange.End, err = stringToFloat(s[1])
-		if err != nil {
-			return thresholdRange, err
-		}
+func validateThreshold(thresholdRange icinga.ThresholdRange) (icinga.ThresholdRange, error) {
+	if thresholdRange.Start > thresholdRange.End {
+		return thresholdRange, errors.New("invalid range: start greater than end")
 	}
-
-	return validateThreshold(thresholdRange)
+	return thresholdRange, nil
 }
 
-func stringToFloat(value string) (float64, error) {
-	if value == "" {
-		return 0, errors.New("empty value")
-	}
+/*
+ * Evaluate a given value against the threshold ranges
+ */
 
-	if value == "~" {
-		return math.Inf(-1), nil
-	}
+// MissingPolicy selects the exit code of a value which is NaN, i.e. missing or unavailable
+type MissingPolicy int
 
-	result, err := strconv.ParseFloat(value, 64)
+const (
+	// MissingUnknown is the zero value and results in UNKNOWN
+	MissingUnknown MissingPolicy = iota
+	MissingOk
+	MissingWarning
+	MissingCritical
+)
 
-	if err != nil {
-		return 0, err
+// ExitCode returns the exit code of a missing value
+func (p MissingPolicy) ExitCode() icinga.ExitCode {
+	switch p {
+	case MissingOk:
+		return icinga.ExitOk
+	case MissingWarning:
+		return icinga.ExitWarning
+	case MissingCritical:
+		return icinga.ExitCritical
+	default:
+		return icinga.ExitUnknown
 	}
+}
 
-	return result, nil
+// Options of an evaluation, the zero value evaluates like Evaluate
+type Options struct {
+	// UnknownList contains ranges resulting in UNKNOWN, e.g. the limits of a sensor
+	UnknownList []icinga.ThresholdRange
+	// Missing selects the exit code of a missing value
+	Missing MissingPolicy
 }
 
-func validateThreshold(thresholdRange icinga.ThresholdRange) (icinga.ThresholdRange, error) {
-	if thresholdRange.Start > thresholdRange.End {
-		return thresholdRange, errors.New("invalid range: start greater than end")
-	}
-	return thresholdRange, nil
+// DefaultOptions returns the options used by Evaluate
+func DefaultOptions() Options {
+	return Options{Missing: MissingUnknown}
 }
 
-/*
- * Evaluate a given value against the threshold ranges
- */
+// Evaluate evaluates the value against the warning and critical ranges, a NaN value results in UNKNOWN
 func Evaluate(warningList []icinga.ThresholdRange, criticalList []icinga.ThresholdRange,
 	value float64, perfData *perfdata.PerformanceData) icinga.ExitCode {
+	return EvaluateWithOptions(warningList, criticalList, value, perfData, DefaultOptions())
+}
+
+// EvaluateWithOptions evaluates the value against the unknown, critical and warning ranges in this order
+func EvaluateWithOptions(warningList []icinga.ThresholdRange, criticalList []icinga.ThresholdRange,
+	value float64, perfData *perfdata.PerformanceData, options Options) icinga.ExitCode {
 
 	thresholdWarning := getThreshold(warningList, perfData)
 	thresholdCritical := getThreshold(criticalList, perfData)
+	thresholdUnknown := getThreshold(options.UnknownList, perfData)
 
+	// compound thresholds cannot be expressed in performance data
 	if perfData != nil {
-		if thresholdCritical != nil {
+		if thresholdCritical != nil && !thresholdCritical.IsCompound() {
 			perfData.SetCritical(thresholdCritical.Definition)
 		}
-		if thresholdWarning != nil {
+		if thresholdWarning != nil && !thresholdWarning.IsCompound() {
 			perfData.SetWarning(thresholdWarning.Definition)
 		}
 	}
 
+	if math.IsNaN(value) {
+		return options.Missing.ExitCode()
+	}
+
+	if thresholdUnknown != nil {
+		if isValueOutOfRange(*thresholdUnknown, value) {
+			return icinga.ExitUnknown
+		}
+	}
+
 	if thresholdCritical != nil {
 		if isValueOutOfRange(*thresholdCritical, value) {
 			return icinga.ExitCritical
@@ -188,7 +177,8 @@ func Evaluate(warningList []icinga.ThresholdRange, criticalList []icinga.Thresho
 	return icinga.ExitOk
 }
 
-// Get threshold
+// Get threshold of the metric, labels like "db1::connections" fall back to
+// the threshold of the metric "connections"
 func getThreshold(list []icinga.ThresholdRange, perfData *perfdata.PerformanceData) *icinga.ThresholdRange {
 	metric := ""
 	if perfData != nil {
@@ -200,10 +190,25 @@ func getThreshold(list []icinga.ThresholdRange, perfData *perfdata.PerformanceDa
 			return &l
 		}
 	}
+
+	if short := perfdata.Metric(metric); short != metric {
+		for _, l := range list {
+			if l.Metric == short {
+				return &l
+			}
+		}
+	}
 	return nil
 }
 
 func isValueOutOfRange(thresholdRange icinga.ThresholdRange, value float64) bool {
+	switch thresholdRange.Operator {
+	case icinga.OperatorAnd:
+		return isValueOutOfRange(*thresholdRange.Left, value) && isValueOutOfRange(*thresholdRange.Right, value)
+	case icinga.OperatorOr:
+		return isValueOutOfRange(*thresholdRange.Left, value) || isValueOutOfRange(*thresholdRange.Right, value)
+	}
+
 	if thresholdRange.Inside {
 		// normally value is inside range
 		if value < thresholdRange.Start || value > thresholdRange.End {
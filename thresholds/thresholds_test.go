@@ -215,3 +215,57 @@ func test_StringToFloatSuccess(t *testing.T, input string, expected float64) {
 		t.Errorf("StringToFloat was incorrect, got: %f, want: %f.", result, expected)
 	}
 }
+
+func TestEvaluateUnknown(t *testing.T) {
+	unknown, _ := ParseThresholdList("temperature,-40:125")
+	warning, _ := ParseThresholdList("temperature,10:30")
+	critical, _ := ParseThresholdList("temperature,5:40")
+
+	options := DefaultOptions()
+	options.UnknownList = unknown
+
+	evaluateUnknown(t, warning, critical, 20, options, icinga.ExitOk)
+	evaluateUnknown(t, warning, critical, 35, options, icinga.ExitWarning)
+	evaluateUnknown(t, warning, critical, 45, options, icinga.ExitCritical)
+	evaluateUnknown(t, warning, critical, 126, options, icinga.ExitUnknown)
+	evaluateUnknown(t, warning, critical, -41, options, icinga.ExitUnknown)
+	evaluateUnknown(t, warning, critical, math.NaN(), options, icinga.ExitUnknown)
+
+	options.Missing = MissingCritical
+	evaluateUnknown(t, warning, critical, math.NaN(), options, icinga.ExitCritical)
+
+	// the zero value of the options treats a missing value as UNKNOWN
+	evaluateUnknown(t, warning, critical, math.NaN(), Options{UnknownList: unknown}, icinga.ExitUnknown)
+	evaluateUnknown(t, warning, critical, 126, Options{UnknownList: unknown}, icinga.ExitUnknown)
+
+	pd := perfdata.CreateUnknownPerformanceData("temperature", "C")
+	if code := Evaluate(warning, critical, pd.Value, pd); code != icinga.ExitUnknown {
+		t.Errorf("Expecting %s, got %s", icinga.ExitUnknown, code)
+	}
+	want := "'temperature'=U;10:30;5:40;;"
+	if pd.String() != want {
+		t.Errorf("Unexpected performance data, got: %s, want: %s.", pd.String(), want)
+	}
+}
+
+func evaluateUnknown(t *testing.T, warning []icinga.ThresholdRange, critical []icinga.ThresholdRange,
+	value float64, options Options, expected icinga.ExitCode) {
+	pd := perfdata.CreatePerformanceData("temperature", value, "C")
+	if code := EvaluateWithOptions(warning, critical, value, pd, options); code != expected {
+		t.Errorf("Expecting %s for %f, got %s", expected, value, code)
+	}
+}
+
+func TestThresholdOfInstance(t *testing.T) {
+	list, _ := ParseThresholdList("connections,100;db2::connections,50")
+
+	pd := perfdata.CreatePerformanceData("db1::connections", 80, "")
+	if code := Evaluate(nil, list, 80, pd); code != icinga.ExitOk {
+		t.Errorf("Expecting %s, got %s", icinga.ExitOk, code)
+	}
+
+	pd = perfdata.CreatePerformanceData("db2::connections", 80, "")
+	if code := Evaluate(nil, list, 80, pd); code != icinga.ExitCritical {
+		t.Errorf("Expecting %s, got %s", icinga.ExitCritical, code)
+	}
+}